@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"github.com/the-gopak/gopak-cli/internal/assets"
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/textdiff"
+)
+
+func init() {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and edit the merged configuration",
+	}
+
+	var format string
+	var provenance bool
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the merged configuration",
+		Long: "Print the configuration after merging the built-in sources, every config file and runtime defaults.\n" +
+			"With --provenance each entry and field is annotated with the file and line that defined it.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Get()
+			var p config.Provenance
+			if provenance {
+				var err error
+				if p, err = config.LoadProvenance(assets.DefaultSources, cfgFiles); err != nil {
+					return err
+				}
+			}
+			switch format {
+			case "yaml":
+				b, err := config.EncodeYAML(cfg, p)
+				if err != nil {
+					return err
+				}
+				fmt.Print(string(b))
+			case "json":
+				var v any = cfg
+				if provenance {
+					v = struct {
+						Config     config.Config     `json:"config"`
+						Provenance config.Provenance `json:"provenance"`
+					}{cfg, p}
+				}
+				b, err := json.MarshalIndent(v, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+			default:
+				return fmt.Errorf("unknown format %q (want yaml or json)", format)
+			}
+			return nil
+		},
+	}
+	showCmd.Flags().StringVar(&format, "format", "yaml", "output format: yaml or json")
+	showCmd.Flags().BoolVar(&provenance, "provenance", false, "annotate every entry and field with the file and line that defined it")
+
+	editCmd := &cobra.Command{
+		Use:   "edit <name>",
+		Short: "Open $EDITOR where a package or source is defined",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := config.LoadProvenance(assets.DefaultSources, cfgFiles)
+			if err != nil {
+				return err
+			}
+			o, ok := p.Lookup(args[0])
+			if !ok {
+				return fmt.Errorf("%s is not defined in any config file", args[0])
+			}
+			if o.File == config.DefaultsFile {
+				return fmt.Errorf("%s comes from the built-in defaults; override it in a file in %s", args[0], cfgDir)
+			}
+			if strings.Contains(o.File, "://") {
+				return fmt.Errorf("%s comes from the remote include %s; override it in a file in %s", args[0], o.File, cfgDir)
+			}
+			return openEditor(o.File, o.Line)
+		},
+	}
+
+	var dryRun, yes bool
+	migrateCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runMigrate(dryRun, yes)
+	}
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only print the changes")
+	migrateCmd.Flags().BoolVarP(&yes, "yes", "y", false, "rewrite the files without prompting")
+
+	configCmd.AddCommand(showCmd, editCmd, migrateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade config files to the current format version",
+	Long: "Rewrite YAML config files written for older versions of gopak in the current format, keeping comments.\n" +
+		"The changes are shown as a diff before anything is written. JSON, TOML and remote files are upgraded when loaded but not rewritten.",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+}
+
+// runMigrate shows how every outdated YAML config file changes and rewrites
+// them after confirmation.
+func runMigrate(dryRun, yes bool) error {
+	files, other, err := config.FilesToMigrate(cfgFiles)
+	if err != nil {
+		return err
+	}
+	type change struct {
+		path string
+		data []byte
+	}
+	var changes []change
+	for _, f := range files {
+		old, migrated, changed, err := config.MigrateFile(f)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+		fmt.Print(textdiff.Unified(f, f+" (migrated)", string(old), string(migrated)))
+		changes = append(changes, change{f, migrated})
+	}
+	for _, f := range other {
+		fmt.Printf("%s: not rewritten; JSON, TOML and remote files are upgraded when loaded\n", f)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("Config files are at version %d\n", config.CurrentVersion)
+		return nil
+	}
+	if dryRun {
+		return nil
+	}
+	if !yes {
+		ok := false
+		if err := survey.AskOne(&survey.Confirm{Message: fmt.Sprintf("Rewrite %d file(s)?", len(changes)), Default: true}, &ok); err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+	for _, c := range changes {
+		mode := os.FileMode(0o644)
+		if st, err := os.Stat(c.path); err == nil {
+			mode = st.Mode().Perm()
+		}
+		if err := os.WriteFile(c.path, c.data, mode); err != nil {
+			return err
+		}
+		fmt.Printf("migrated %s to version %d\n", c.path, config.CurrentVersion)
+	}
+	return nil
+}
+
+// openEditor opens file at line in $VISUAL or $EDITOR, falling back to vi.
+func openEditor(file string, line int) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	parts := strings.Fields(editor)
+	c := exec.Command(parts[0], append(parts[1:], editorArgs(parts[0], file, line)...)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return c.Run()
+}
+
+// editorArgs returns the arguments that open file at line. Most terminal
+// editors accept +line; VS Code and similar editors take file:line. Without a
+// line, as for TOML files, only the file is passed.
+func editorArgs(editor, file string, line int) []string {
+	if line == 0 {
+		return []string{file}
+	}
+	switch strings.TrimSuffix(filepath.Base(editor), ".exe") {
+	case "code", "code-insiders", "codium", "cursor":
+		return []string{"-g", file + ":" + strconv.Itoa(line)}
+	case "subl", "zed", "hx":
+		return []string{file + ":" + strconv.Itoa(line)}
+	}
+	return []string{"+" + strconv.Itoa(line), file}
+}
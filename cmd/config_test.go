@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditorArgs(t *testing.T) {
+	cases := []struct {
+		editor string
+		want   []string
+	}{
+		{"vim", []string{"+12", "a.yaml"}},
+		{"/usr/bin/nano", []string{"+12", "a.yaml"}},
+		{"code", []string{"-g", "a.yaml:12"}},
+		{"subl", []string{"a.yaml:12"}},
+	}
+	for _, c := range cases {
+		if got := editorArgs(c.editor, "a.yaml", 12); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("editorArgs(%q) = %v, want %v", c.editor, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/ui/console"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "graph [name]",
+		Short: "Print the dependency graph",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			cfg := config.Get()
+			m := newManager(cfg)
+			ui := console.NewConsoleUI(m)
+			return ui.Graph(name, format)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "dot", "output format: dot, mermaid or json")
+	rootCmd.AddCommand(cmd)
+}
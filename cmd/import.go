@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/the-gopak/gopak-cli/internal/config"
+)
+
+func init() {
+	var source string
+	var output string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Generate a config file from packages already installed on this machine",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(config.Get(), source, output, dryRun, false)
+		},
+	}
+	cmd.Flags().StringVar(&source, "source", "", "only import packages from this source")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file name to write in the config directory (default imported[-<source>].yaml)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the generated YAML instead of writing it")
+	rootCmd.AddCommand(cmd)
+}
+
+// runImport writes the packages installed from source, or from every source,
+// that cfg does not track yet to output in the config directory. With force
+// an existing file is replaced.
+func runImport(cfg config.Config, source, output string, dryRun, force bool) error {
+	m := newManager(cfg)
+	pkgs, err := m.Untracked(source)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) == 0 {
+		fmt.Println("Nothing to import")
+		return nil
+	}
+	merged := cfg
+	merged.Packages = append(append([]config.Package{}, cfg.Packages...), pkgs...)
+	if err := config.ValidateNoDuplicates(merged); err != nil {
+		return err
+	}
+	data, err := config.EncodePackages(pkgs)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Print(string(data))
+		return nil
+	}
+	if output == "" {
+		output = "imported.yaml"
+		if source != "" {
+			output = "imported-" + source + ".yaml"
+		}
+	}
+	path := output
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfgDir, path)
+	}
+	if err := writeNewFile(path, data, force); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists; choose another name with --output", path)
+		}
+		return err
+	}
+	fmt.Printf("imported %d packages into %s\n", len(pkgs), path)
+	return nil
+}
+
+// writeNewFile writes data to path. Unless force is set, an existing file is
+// left alone and an error satisfying os.IsExist is returned.
+func writeNewFile(path string, data []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/the-gopak/gopak-cli/internal/assets"
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/manager"
+	"github.com/spf13/cobra"
+)
+
+// starterFile is the file gopak init writes in the config directory.
+const starterFile = "config.yaml"
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starter config.yaml for the package managers found on this machine",
+	Args:  cobra.NoArgs,
+}
+
+func init() {
+	var force bool
+	var withImport bool
+	initCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bundled, err := config.LoadDefaultsAndFiles(assets.DefaultSources, nil)
+		if err != nil {
+			return err
+		}
+		sources := manager.New(bundled).DetectSources()
+		path := filepath.Join(cfgDir, starterFile)
+		if err := writeNewFile(path, config.StarterConfig(sources), force); err != nil {
+			if os.IsExist(err) {
+				return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+			}
+			return err
+		}
+		if len(sources) == 0 {
+			fmt.Printf("wrote %s; no bundled package manager was found\n", path)
+		} else {
+			fmt.Printf("wrote %s for %d package managers\n", path, len(sources))
+		}
+		if !withImport {
+			return nil
+		}
+		cfg, err := config.LoadDefaultsAndFiles(assets.DefaultSources, configFiles())
+		if err != nil {
+			return fmt.Errorf("config error: %w", err)
+		}
+		return runImport(cfg, "", "", false, force)
+	}
+	initCmd.Flags().BoolVar(&force, "force", false, "overwrite existing files")
+	initCmd.Flags().BoolVar(&withImport, "import", false, "also import the packages already installed, like gopak import")
+	initCmd.SilenceUsage = true
+	rootCmd.AddCommand(initCmd)
+}
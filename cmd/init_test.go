@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := writeNewFile(path, []byte("first\n"), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeNewFile(path, []byte("second\n"), false); !os.IsExist(err) {
+		t.Fatalf("expected an exists error, got %v", err)
+	}
+	if err := writeNewFile(path, []byte("2\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "2\n" {
+		t.Fatalf("file = %q, want it replaced", b)
+	}
+}
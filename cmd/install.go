@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"github.com/the-gopak/gopak-cli/internal/config"
-	"github.com/the-gopak/gopak-cli/internal/manager"
 	"github.com/the-gopak/gopak-cli/internal/ui/console"
 	"github.com/spf13/cobra"
 )
@@ -10,13 +9,19 @@ import (
 func init() {
 	var dryRun bool
 	var yes bool
+	var locked bool
 	cmd := &cobra.Command{
 		Use:   "install [name]",
 		Short: "Install one package or select from uninstalled",
 		Args:  cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := config.Get()
-			m := manager.New(cfg)
+			m := newManager(cfg)
+			if locked {
+				if err := useLockfile(m); err != nil {
+					return err
+				}
+			}
 			name := ""
 			if len(args) == 1 {
 				name = args[0]
@@ -27,5 +32,7 @@ func init() {
 	}
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print planned changes without executing")
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "assume yes and install all without prompting")
+	cmd.Flags().BoolVar(&locked, "locked", false, "install exactly the versions recorded in gopak.lock")
+	addTagFlags(cmd)
 	rootCmd.AddCommand(cmd)
 }
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/lockfile"
+)
+
+func init() {
+	var update bool
+	cmd := &cobra.Command{
+		Use:   "lock [name...]",
+		Short: "Record the resolved version of every package in gopak.lock",
+		Long: "Record the resolved version of every package in gopak.lock next to the configuration.\n" +
+			"Packages already in the lockfile keep their version unless --update is given.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Get()
+			m := newManager(cfg)
+			l, err := lockfile.Load(cfgDir)
+			if err != nil {
+				return err
+			}
+			return m.UpdateLock(l, args, update)
+		},
+	}
+	cmd.Flags().BoolVar(&update, "update", false, "resolve the named packages (or all) again instead of keeping locked versions")
+	rootCmd.AddCommand(cmd)
+}
@@ -1,8 +1,9 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/the-gopak/gopak-cli/internal/config"
-	"github.com/the-gopak/gopak-cli/internal/manager"
 	"github.com/the-gopak/gopak-cli/internal/ui/console"
 	"github.com/spf13/cobra"
 )
@@ -10,16 +11,23 @@ import (
 func init() {
 	var yes bool
 	cmd := &cobra.Command{
-		Use:   "remove <name>",
-		Short: "Remove a package",
-		Args:  cobra.ExactArgs(1),
+		Use:   "remove [name]",
+		Short: "Remove a package, or every installed package with --tag",
+		Args:  cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && len(tags) == 0 {
+				return errors.New("remove needs a package name or --tag")
+			}
 			cfg := config.Get()
-			m := manager.New(cfg)
+			m := newManager(cfg)
 			ui := console.NewConsoleUI(m)
+			if len(args) == 0 {
+				return ui.RunRemoveTracked(yes)
+			}
 			return ui.RunRemoveImperative(args[0], yes)
 		},
 	}
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "assume yes and remove without prompting")
+	addTagFlags(cmd)
 	rootCmd.AddCommand(cmd)
 }
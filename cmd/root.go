@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -10,30 +11,69 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/the-gopak/gopak-cli/internal/assets"
 	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/executil"
+	"github.com/the-gopak/gopak-cli/internal/lockfile"
 	"github.com/the-gopak/gopak-cli/internal/logging"
+	"github.com/the-gopak/gopak-cli/internal/manager"
+	"github.com/the-gopak/gopak-cli/internal/secret"
+	"github.com/the-gopak/gopak-cli/internal/state"
 )
 
 var cfgFile string
+var cfgDir string
 var verbose bool
+var recursive bool
+var profiles []string
+var tags []string
+var excludeTags []string
+var cfgFiles []string
+var configErr error
 var version = "dev"
 
 var rootCmd = &cobra.Command{
-	Use:   "gopak",
-	Short: "Universal Package Manager",
+	Use:              "gopak",
+	Short:            "Universal Package Manager",
+	PersistentPreRun: checkConfig,
 }
 
 func Execute() error { return rootCmd.Execute() }
 
 func init() {
 	version = resolveVersion(version)
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "path to any YAML file inside the config directory (default dir: ~/.config/gopak); all *.yaml in that directory are merged")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "path to any config file inside the config directory (default dir: ~/.config/gopak); all YAML, JSON and TOML files in that directory are merged")
+	rootCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "r", false, "also merge config files in subdirectories of the config directory, such as conf.d/")
+	rootCmd.PersistentFlags().StringSliceVar(&profiles, "profile", nil, "only consider the packages of these profiles, e.g. work,dev (default $GOPAK_PROFILE)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "show detailed steps and commands")
 	rootCmd.Version = version
 	cobra.OnInitialize(initConfig)
 }
 
 func initConfig() {
-	var cfgDir string
+	cfgFiles = configFiles()
+	cfg, err := config.LoadDefaultsAndFiles(assets.DefaultSources, cfgFiles)
+	if err != nil {
+		configErr = fmt.Errorf("config error: %w", err)
+	} else if err := config.ValidateAgainstSchema(cfg); err != nil {
+		configErr = fmt.Errorf("schema error: %w", err)
+	}
+	logging.Init()
+	logging.SetVerbose(verbose)
+}
+
+// checkConfig stops every command but validate, init and config migrate when
+// the configuration failed to load; validate reports the problems itself,
+// init can replace a broken config.yaml with --force and migrate may fix it.
+func checkConfig(cmd *cobra.Command, args []string) {
+	if configErr != nil && cmd != validateCmd && cmd != initCmd && cmd != migrateCmd {
+		logging.Error(configErr.Error())
+		os.Exit(1)
+	}
+}
+
+// configFiles resolves the configuration directory and returns the config files
+// in it, including subdirectories with --recursive. Files they include are
+// resolved by the loader.
+func configFiles() []string {
 	if cfgFile != "" {
 		cfgDir = filepath.Dir(cfgFile)
 	} else {
@@ -47,30 +87,69 @@ func initConfig() {
 	}
 	// Ensure config directory and default sources.yaml exist
 	_ = os.MkdirAll(cfgDir, 0o755)
-	// Gather all YAML files and load
-	entries, _ := os.ReadDir(cfgDir)
-	var files []string
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		name := e.Name()
-		low := strings.ToLower(name)
-		if strings.HasSuffix(low, ".yaml") || strings.HasSuffix(low, ".yml") {
-			files = append(files, filepath.Join(cfgDir, name))
+	files, _ := config.ConfigFilesIn(cfgDir, recursive)
+	return files
+}
+
+// newManager builds a manager for cfg that records what it installs in the
+// state file of the active configuration directory and only considers the
+// packages of the active profiles and tag filters. Commands resolve secrets
+// from cfg.
+func newManager(cfg config.Config) *manager.Manager {
+	cfg.Dir = cfgDir
+	executil.UseSecrets(secret.New(cfg).Lookup)
+	m := manager.New(cfg)
+	if err := m.UseProfiles(activeProfiles()); err != nil {
+		logging.Error("profile error: " + err.Error())
+		os.Exit(1)
+	}
+	if err := m.UseTags(tags, excludeTags); err != nil {
+		logging.Error("tag error: " + err.Error())
+		os.Exit(1)
+	}
+	st, err := state.NewManager(cfgDir)
+	if err != nil {
+		logging.Error("state error: " + err.Error())
+		return m
+	}
+	m.UseState(st)
+	return m
+}
+
+// addTagFlags registers --tag and --exclude-tag on cmd.
+func addTagFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "only consider packages with one of these tags and their dependencies, e.g. cli,dev")
+	cmd.Flags().StringSliceVar(&excludeTags, "exclude-tag", nil, "leave out packages with any of these tags")
+}
+
+// activeProfiles returns the profiles named by --profile, or by
+// GOPAK_PROFILE when the flag is not given.
+func activeProfiles() []string {
+	names := profiles
+	if len(names) == 0 {
+		names = strings.Split(os.Getenv("GOPAK_PROFILE"), ",")
+	}
+	var out []string
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			out = append(out, n)
 		}
 	}
-	cfg, err := config.LoadDefaultsAndFiles(assets.DefaultSources, files)
+	return out
+}
+
+// useLockfile pins m to the versions recorded in the lockfile of the active
+// configuration directory.
+func useLockfile(m *manager.Manager) error {
+	l, err := lockfile.Load(cfgDir)
 	if err != nil {
-		logging.Error("config error: " + err.Error())
-		os.Exit(1)
+		return err
 	}
-	if err := config.ValidateAgainstSchema(cfg); err != nil {
-		logging.Error("schema error: " + err.Error())
-		os.Exit(1)
+	if !l.Exists() {
+		return fmt.Errorf("no %s found in %s; run gopak lock first", lockfile.FileName, cfgDir)
 	}
-	logging.Init()
-	logging.SetVerbose(verbose)
+	m.UseLock(l)
+	return nil
 }
 
 func resolveVersion(linkerVersion string) string {
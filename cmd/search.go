@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"github.com/the-gopak/gopak-cli/internal/config"
-	"github.com/the-gopak/gopak-cli/internal/manager"
 	"github.com/the-gopak/gopak-cli/internal/ui/console"
 	"github.com/spf13/cobra"
 )
@@ -14,7 +13,7 @@ func init() {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := config.Get()
-			m := manager.New(cfg)
+			m := newManager(cfg)
 			ui := console.NewConsoleUI(m)
 			return ui.RunSearchImperative(args[0])
 		},
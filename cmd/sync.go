@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/ui/console"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var dryRun bool
+	var yes bool
+	var locked bool
+	var prune bool
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Install missing and update outdated packages to match the configuration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Get()
+			m := newManager(cfg)
+			if locked {
+				if err := useLockfile(m); err != nil {
+					return err
+				}
+			}
+			ui := console.NewConsoleUI(m)
+			return ui.Sync(prune, dryRun, yes)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the plan without executing")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "assume yes and apply the plan without prompting")
+	cmd.Flags().BoolVar(&prune, "prune", false, "also remove packages installed by gopak that are no longer configured")
+	cmd.Flags().BoolVar(&locked, "locked", false, "install exactly the versions recorded in gopak.lock")
+	addTagFlags(cmd)
+	rootCmd.AddCommand(cmd)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"github.com/the-gopak/gopak-cli/internal/config"
-	"github.com/the-gopak/gopak-cli/internal/manager"
 	"github.com/the-gopak/gopak-cli/internal/ui/console"
 	"github.com/spf13/cobra"
 )
@@ -16,7 +15,7 @@ func init() {
 		Args:  cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := config.Get()
-			m := manager.New(cfg)
+			m := newManager(cfg)
 			name := ""
 			if len(args) == 1 {
 				name = args[0]
@@ -27,5 +26,6 @@ func init() {
 	}
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print planned changes without executing")
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "assume yes and update all without prompting")
+	addTagFlags(cmd)
 	rootCmd.AddCommand(cmd)
 }
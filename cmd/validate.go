@@ -1,18 +1,60 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+
 	"github.com/spf13/cobra"
+	"github.com/the-gopak/gopak-cli/internal/assets"
+	"github.com/the-gopak/gopak-cli/internal/config"
 )
 
+var validateFormat string
+
 var validateCmd = &cobra.Command{
 	Use:   "validate",
-	Short: "Validate merged configuration against the JSON Schema",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Configuration is valid")
+	Short: "Check the configuration files and report every problem",
+	Long: "Check the configuration files and report every problem with its file and line:\n" +
+		"undefined sources, unknown dependencies, missing commands, misplaced placeholders,\n" +
+		"invalid version constraints and version_regex values, and shell syntax errors.\n" +
+		"Exits non-zero when problems are found.",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issues := config.Lint(assets.DefaultSources, cfgFiles)
+		if len(issues) == 0 && configErr != nil {
+			issues = append(issues, config.Issue{Rule: "config", Message: configErr.Error()})
+		}
+		switch validateFormat {
+		case "json":
+			if issues == nil {
+				issues = []config.Issue{}
+			}
+			b, err := json.MarshalIndent(issues, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+			cmd.SilenceErrors = len(issues) > 0
+		case "text":
+			if len(issues) == 0 {
+				fmt.Println("Configuration is valid")
+				return nil
+			}
+			for _, is := range issues {
+				fmt.Println(is.String())
+			}
+		default:
+			return fmt.Errorf("unknown format %q (want text or json)", validateFormat)
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("%d problem(s) found", len(issues))
+		}
+		return nil
 	},
 }
 
 func init() {
+	validateCmd.Flags().StringVar(&validateFormat, "format", "text", "output format: text or json")
 	rootCmd.AddCommand(validateCmd)
 }
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/ui/console"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "why <name>",
+		Short: "Show which packages depend on a package",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Get()
+			m := newManager(cfg)
+			ui := console.NewConsoleUI(m)
+			return ui.Why(args[0])
+		},
+	}
+	rootCmd.AddCommand(cmd)
+}
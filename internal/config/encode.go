@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaURL is the published location of the configuration JSON Schema,
+// referenced from generated files for editor completion.
+const SchemaURL = "https://raw.githubusercontent.com/the-gopak/gopak-cli/HEAD/schema/gopak.schema.json"
+
+type encodedPackage struct {
+	Name   string `yaml:"name"`
+	Source string `yaml:"source"`
+}
+
+type encodedPackages struct {
+	Schema   string           `yaml:"$schema"`
+	Version  int              `yaml:"version"`
+	Packages []encodedPackage `yaml:"packages"`
+}
+
+// EncodePackages renders source packages as a standalone configuration file.
+// Only the name and source are written; everything else keeps its default.
+func EncodePackages(pkgs []Package) ([]byte, error) {
+	doc := encodedPackages{Schema: SchemaURL, Version: CurrentVersion}
+	for _, p := range pkgs {
+		doc.Packages = append(doc.Packages, encodedPackage{Name: p.Name, Source: p.Source})
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// starterExamples are example packages of the bundled sources, written as
+// comments into a starter configuration.
+var starterExamples = map[string]string{
+	"apt":     "git",
+	"pacman":  "git",
+	"snap":    "code",
+	"flatpak": "org.mozilla.firefox",
+	"pipx":    "httpie",
+	"npm":     "typescript",
+	"npx":     "prettier",
+}
+
+// StarterConfig renders a commented starter configuration with a packages
+// skeleton holding one commented example for each of sources.
+func StarterConfig(sources []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "$schema: %s\nversion: %d\n\n", SchemaURL, CurrentVersion)
+	b.WriteString("# Gopak configuration. List the programs you want under packages, then run\n")
+	b.WriteString("# \"gopak sync\" to install them. \"gopak validate\" checks this file.\n")
+	if len(sources) == 0 {
+		b.WriteString("#\n# No bundled package manager was found on this machine. Define a source under\n")
+		b.WriteString("# sources, or use custom_packages and github_release_packages.\n\n")
+		b.WriteString("packages:\n")
+		b.WriteString("  # - name: git\n  #   source: apt\n")
+		return []byte(b.String())
+	}
+	fmt.Fprintf(&b, "#\n# Package managers found on this machine: %s.\n\n", strings.Join(sources, ", "))
+	b.WriteString("packages:\n")
+	for _, s := range sources {
+		name := starterExamples[s]
+		if name == "" {
+			name = "example"
+		}
+		fmt.Fprintf(&b, "  # - name: %s\n  #   source: %s\n", name, s)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEncodePackages_RoundTripsAndPassesSchema(t *testing.T) {
+	in := []Package{{Name: "git", Source: "apt"}, {Name: "org.gimp.GIMP", Source: "flatpak"}}
+	data, err := EncodePackages(in)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("generated YAML must parse: %v\n%s", err, data)
+	}
+	if len(cfg.Packages) != 2 || cfg.Packages[1].Name != "org.gimp.GIMP" || cfg.Packages[1].Source != "flatpak" {
+		t.Fatalf("unexpected packages: %#v", cfg.Packages)
+	}
+	if err := ValidateAgainstSchema(cfg); err != nil {
+		t.Fatalf("generated config must pass schema validation: %v", err)
+	}
+}
+
+func TestStarterConfig(t *testing.T) {
+	for _, sources := range [][]string{{"apt", "flatpak"}, nil} {
+		data := StarterConfig(sources)
+		var cfg Config
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			t.Fatalf("starter config must parse: %v\n%s", err, data)
+		}
+		if len(cfg.Packages) != 0 {
+			t.Fatalf("starter config should only hold commented examples: %#v", cfg.Packages)
+		}
+		if err := ValidateAgainstSchema(cfg); err != nil {
+			t.Fatalf("starter config must pass schema validation: %v", err)
+		}
+		if !strings.Contains(string(data), SchemaURL) {
+			t.Fatalf("missing $schema:\n%s", data)
+		}
+	}
+	data := string(StarterConfig([]string{"apt", "flatpak"}))
+	if !strings.Contains(data, "#   source: apt") || !strings.Contains(data, "#   source: flatpak") || !strings.Contains(data, "found on this machine: apt, flatpak") {
+		t.Fatalf("unexpected starter config:\n%s", data)
+	}
+}
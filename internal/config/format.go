@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configExts are the extensions of configuration files.
+var configExts = []string{".yaml", ".yml", ".json", ".toml"}
+
+// StateFile is kept in the configuration directory by the state package. It
+// is JSON but not configuration.
+const StateFile = "state.json"
+
+// IsConfigFile reports whether name is a YAML, JSON or TOML configuration
+// file.
+func IsConfigFile(name string) bool {
+	if filepath.Base(name) == StateFile {
+		return false
+	}
+	return slices.Contains(configExts, strings.ToLower(filepath.Ext(name)))
+}
+
+// parseDocument parses a configuration file into a YAML document node and
+// migrates it to CurrentVersion, so every format and version decodes through
+// the same string-or-object shorthands. The format follows the extension of
+// name and defaults to YAML. JSON keeps its positions, as JSON is also YAML;
+// TOML nodes carry none. Errors start with "line N:" when the position is
+// known.
+func parseDocument(name string, b []byte) (*yaml.Node, error) {
+	doc, err := parseFormat(name, b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrateDocument(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// formatExt returns the lower-case extension of a file name or URL.
+func formatExt(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" && u.Host != "" {
+		return strings.ToLower(path.Ext(u.Path))
+	}
+	return strings.ToLower(filepath.Ext(name))
+}
+
+func parseFormat(name string, b []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	switch formatExt(name) {
+	case ".json":
+		if err := checkJSON(b); err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(b, &doc); err == nil {
+			return &doc, nil
+		}
+		var v any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+		return encodeDocument(v)
+	case ".toml":
+		var v map[string]any
+		if err := toml.Unmarshal(b, &v); err != nil {
+			var de *toml.DecodeError
+			if errors.As(err, &de) {
+				row, _ := de.Position()
+				return nil, fmt.Errorf("line %d: %s", row, de.Error())
+			}
+			return nil, err
+		}
+		return encodeDocument(v)
+	default:
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		return &doc, nil
+	}
+}
+
+// checkJSON rejects what is valid YAML but not JSON, with the line of the
+// first syntax error.
+func checkJSON(b []byte) error {
+	var v any
+	err := json.Unmarshal(b, &v)
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		return fmt.Errorf("line %d: %s", bytes.Count(b[:se.Offset], []byte("\n"))+1, se.Error())
+	}
+	return err
+}
+
+func encodeDocument(v any) (*yaml.Node, error) {
+	var n yaml.Node
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&n}}, nil
+}
+
+// decodeConfig parses a configuration file in any supported format.
+func decodeConfig(name string, b []byte) (Config, error) {
+	var cfg Config
+	doc, err := parseDocument(name, b)
+	if err != nil {
+		return Config{}, err
+	}
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+	if err := doc.Decode(&cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
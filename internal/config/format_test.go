@@ -0,0 +1,105 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/assets"
+)
+
+func TestLoadDefaultsAndFiles_JSONAndTOML(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), `packages:
+  - name: git
+    source: apt
+`)
+	writeFile(t, filepath.Join(dir, "b.json"), `{
+	"custom_packages": [
+		{
+			"name": "tool",
+			"executable": ["tool", "--quiet"],
+			"get_installed_version": "tool --version",
+			"install": {"command": "install-tool", "require_root": true},
+			"remove": "rm tool"
+		}
+	]
+}
+`)
+	writeFile(t, filepath.Join(dir, "c.toml"), `[[packages]]
+name = "htop"
+source = "apt"
+executable = "htop"
+
+[[sources]]
+name = "apt"
+install = { command = "apt-get install -y {package_list}", require_root = true }
+`)
+	files, err := ConfigFilesIn(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadDefaultsAndFiles(assets.DefaultSources, files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := ValidateAgainstSchema(cfg); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	var names []string
+	for _, p := range cfg.Packages {
+		names = append(names, p.Name)
+		if p.Name == "htop" && p.Executable.Binary() != "htop" {
+			t.Fatalf("htop executable = %v", p.Executable)
+		}
+	}
+	if got := strings.Join(names, ","); !strings.Contains(got, "git") || !strings.Contains(got, "htop") {
+		t.Fatalf("packages = %s", got)
+	}
+	tool := cfg.CustomPackages[0]
+	if tool.Executable.Binary() != "tool" || len(tool.Executable.Args()) != 1 {
+		t.Fatalf("tool executable = %v", tool.Executable)
+	}
+	if tool.GetInstalledVersion.Command != "tool --version" || !tool.Install.RequireRoot {
+		t.Fatalf("tool commands = %+v", tool)
+	}
+	for _, s := range cfg.Sources {
+		if s.Name == "apt" && (s.Install.Command != "apt-get install -y {package_list}" || !s.Install.RequireRoot || s.Remove.Command == "") {
+			t.Fatalf("apt not overlaid from TOML: %+v", s)
+		}
+	}
+}
+
+func TestLoadFromFiles_DuplicateAcrossFormats(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.toml")
+	writeFile(t, a, "packages:\n  - name: git\n    source: apt\n")
+	writeFile(t, b, "[[packages]]\nname = \"git\"\nsource = \"apt\"\n")
+	_, err := LoadFromFiles([]string{a, b})
+	if err == nil || !strings.Contains(err.Error(), a) || !strings.Contains(err.Error(), b) {
+		t.Fatalf("expected duplicate error naming both files, got %v", err)
+	}
+}
+
+func TestLint_JSONAndTOMLPositions(t *testing.T) {
+	dir := t.TempDir()
+	j := filepath.Join(dir, "a.json")
+	writeFile(t, j, "{\n  \"packages\": [\n    {\"name\": \"git\", \"source\": \"nope\"}\n  ]\n}\n")
+	issues := Lint(assets.DefaultSources, []string{j})
+	if len(issues) != 1 || issues[0].Rule != RuleUndefinedSource || issues[0].Line != 3 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	bad := filepath.Join(dir, "b.toml")
+	writeFile(t, bad, "[[packages]]\nname = \"git\"\nsource = \n")
+	issues = Lint(nil, []string{bad})
+	if len(issues) != 1 || issues[0].Rule != RuleParse || issues[0].Line != 3 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	trailing := filepath.Join(dir, "c.json")
+	writeFile(t, trailing, "{\n  \"packages\": [],\n}\n")
+	issues = Lint(nil, []string{trailing})
+	if len(issues) != 1 || issues[0].Rule != RuleParse || issues[0].Line != 3 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configFile is a file to load together with the file whose include pulled
+// it in; From is empty for files given directly. URL is set for remote
+// includes, whose Path is the cached copy.
+type configFile struct {
+	Path string
+	URL  string
+	From string
+}
+
+// name is the URL of a remote file and the path of a local one.
+func (f configFile) name() string {
+	if f.URL != "" {
+		return f.URL
+	}
+	return f.Path
+}
+
+// label names the file in error messages.
+func (f configFile) label() string {
+	if f.From == "" {
+		return f.name()
+	}
+	return fmt.Sprintf("%s (included from %s)", f.name(), f.From)
+}
+
+// expandIncludes returns the configuration files among files together with everything
+// they include, in load order: the files an include names are loaded before
+// the file that names them, so the including file can override them. Each file
+// is loaded once; a file that includes itself, directly or not, is an error.
+// Remote includes are fetched into the cache first.
+func expandIncludes(files []string) ([]configFile, error) {
+	var out []configFile
+	done := map[string]bool{}
+	var stack []string
+	var visit func(f configFile) error
+	visit = func(f configFile) error {
+		abs, err := filepath.Abs(f.Path)
+		if err != nil {
+			return err
+		}
+		for i, s := range stack {
+			if s == abs {
+				return fmt.Errorf("include cycle: %s", strings.Join(append(stack[i:], abs), " -> "))
+			}
+		}
+		if done[abs] {
+			return nil
+		}
+		b, err := os.ReadFile(f.Path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.label(), err)
+		}
+		// A file that does not parse is reported when it is loaded.
+		head, _ := decodeConfig(f.Path, b)
+		stack = append(stack, abs)
+		for _, inc := range head.Include {
+			if inc.URL != "" {
+				p, err := fetchInclude(inc)
+				if err != nil {
+					return fmt.Errorf("%s: %w", f.label(), err)
+				}
+				if err := visit(configFile{Path: p, URL: inc.URL, From: f.name()}); err != nil {
+					return err
+				}
+				continue
+			}
+			if f.URL != "" {
+				return fmt.Errorf("%s: include %q: remote files can only include URLs", f.label(), inc.Path)
+			}
+			matches, err := resolveInclude(filepath.Dir(f.Path), inc.Path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.label(), err)
+			}
+			for _, m := range matches {
+				if err := visit(configFile{Path: m, From: f.Path}); err != nil {
+					return err
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		done[abs] = true
+		out = append(out, f)
+		return nil
+	}
+	for _, f := range sortedConfigFiles(files) {
+		if err := visit(configFile{Path: f}); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// resolveInclude expands one include entry relative to dir. The entry may be
+// a file, a glob or a directory; directories contribute every configuration
+// file below them. A path without glob characters must exist.
+func resolveInclude(dir, pattern string) ([]string, error) {
+	p := pattern
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	matches, err := filepath.Glob(p)
+	if err != nil {
+		return nil, fmt.Errorf("include %q: %w", pattern, err)
+	}
+	if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, fmt.Errorf("include %q: no such file or directory", pattern)
+	}
+	var out []string
+	for _, m := range matches {
+		st, err := os.Stat(m)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", pattern, err)
+		}
+		if !st.IsDir() {
+			out = append(out, m)
+			continue
+		}
+		found, err := ConfigFilesIn(m, true)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", pattern, err)
+		}
+		out = append(out, found...)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// ConfigFilesIn lists the .yaml, .yml, .json and .toml files in dir, sorted.
+// With recursive subdirectories are searched too.
+func ConfigFilesIn(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if IsConfigFile(d.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
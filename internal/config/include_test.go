@@ -0,0 +1,206 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDefaultsAndFiles_Include(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	writeFile(t, main, `include:
+  - team/*.yaml
+  - conf.d
+sources:
+  - type: package_manager
+    name: apt
+    install: "apt-get install -y {package}"
+packages:
+  - name: personal
+    source: apt
+`)
+	writeFile(t, filepath.Join(dir, "team", "base.yaml"), `sources:
+  - type: package_manager
+    name: apt
+    install: "team-install {package}"
+    remove: "team-remove {package}"
+packages:
+  - name: shared
+    source: apt
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "nested", "extra.yml"), `packages:
+  - name: nested
+    source: apt
+`)
+	cfg, err := LoadDefaultsAndFiles(nil, []string{main})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var names []string
+	for _, p := range cfg.Packages {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "shared,nested,personal" {
+		t.Fatalf("packages = %v, want included files first", names)
+	}
+	if len(cfg.Sources) != 1 || cfg.Sources[0].Install.Command != "apt-get install -y {package}" || cfg.Sources[0].Remove.Command != "team-remove {package}" {
+		t.Fatalf("including file should override included source: %+v", cfg.Sources)
+	}
+}
+
+func TestLoadDefaultsAndFiles_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	writeFile(t, a, "include: [sub/b.yaml]\n")
+	writeFile(t, filepath.Join(dir, "sub", "b.yaml"), "include: [../a.yaml]\n")
+	_, err := LoadDefaultsAndFiles(nil, []string{a})
+	if err == nil || !strings.Contains(err.Error(), "include cycle: ") || !strings.HasSuffix(err.Error(), "a.yaml") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestLoadDefaultsAndFiles_IncludeErrorsNameIncludingFile(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	writeFile(t, a, "include: [shared.yaml]\npackages:\n  - name: git\n    source: apt\n")
+	writeFile(t, filepath.Join(dir, "shared.yaml"), "packages:\n  - name: git\n    source: apt\n")
+	_, err := LoadDefaultsAndFiles(nil, []string{a})
+	if err == nil || !strings.Contains(err.Error(), "shared.yaml (included from "+a+")") {
+		t.Fatalf("expected error naming the including file, got %v", err)
+	}
+
+	writeFile(t, a, "include: [missing.yaml]\n")
+	_, err = LoadDefaultsAndFiles(nil, []string{a})
+	if err == nil || !strings.Contains(err.Error(), `include "missing.yaml"`) || !strings.Contains(err.Error(), a) {
+		t.Fatalf("expected missing include error, got %v", err)
+	}
+}
+
+func TestConfigFilesIn(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "")
+	writeFile(t, filepath.Join(dir, "b.toml"), "")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "")
+	writeFile(t, filepath.Join(dir, StateFile), "{}")
+	writeFile(t, filepath.Join(dir, "conf.d", "c.json"), "{}")
+	top, err := ConfigFilesIn(dir, false)
+	if err != nil || len(top) != 2 {
+		t.Fatalf("top level: %v, %v", top, err)
+	}
+	all, err := ConfigFilesIn(dir, true)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("recursive: %v, %v", all, err)
+	}
+}
+
+// remoteInclude serves body over HTTPS and points the cache at a temporary
+// directory. Setting *fail makes the server answer 503.
+func remoteInclude(t *testing.T, body string) (string, *bool) {
+	t.Helper()
+	fail := new(bool)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	prev := httpClient
+	httpClient = srv.Client()
+	t.Cleanup(func() { httpClient = prev })
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	return srv.URL + "/team/base.yaml", fail
+}
+
+func TestLoadDefaultsAndFiles_RemoteIncludePinned(t *testing.T) {
+	body := "packages:\n  - name: shared\n    source: apt\n"
+	u, fail := remoteInclude(t, body)
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	pinned := "include:\n  - url: " + u + "\n    sha256: " + sha256Hex([]byte(body)) + "\n"
+	writeFile(t, main, pinned+"packages:\n  - name: personal\n    source: apt\n")
+
+	cfg, err := LoadDefaultsAndFiles(nil, []string{main})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.Packages) != 2 || cfg.Packages[0].Name != "shared" {
+		t.Fatalf("packages = %+v", cfg.Packages)
+	}
+
+	// The pinned copy in the cache is used without downloading.
+	*fail = true
+	if _, err := LoadDefaultsAndFiles(nil, []string{main}); err != nil {
+		t.Fatalf("load from cache: %v", err)
+	}
+
+	*fail = false
+	writeFile(t, main, "include:\n  - url: "+u+"\n    sha256: "+strings.Repeat("0", 64)+"\n")
+	_, err = LoadDefaultsAndFiles(nil, []string{main})
+	if err == nil || !strings.Contains(err.Error(), "sha256 mismatch") || !strings.Contains(err.Error(), main) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+}
+
+func TestLoadDefaultsAndFiles_RemoteIncludeRefreshOffline(t *testing.T) {
+	u, fail := remoteInclude(t, "packages:\n  - name: shared\n    source: apt\n")
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	writeFile(t, main, "include:\n  - url: "+u+"\n    refresh: 1h\n")
+	if _, err := LoadDefaultsAndFiles(nil, []string{main}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var warnings []string
+	prev := warn
+	warn = func(msg string) { warnings = append(warnings, msg) }
+	defer func() { warn = prev }()
+	*fail = true
+	old := time.Now().Add(-2 * time.Hour)
+	cached, _ := filepath.Glob(filepath.Join(CacheDir(), "includes", "*.yaml"))
+	if len(cached) != 1 {
+		t.Fatalf("cache = %v", cached)
+	}
+	if err := os.Chtimes(cached[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadDefaultsAndFiles(nil, []string{main})
+	if err != nil {
+		t.Fatalf("offline load: %v", err)
+	}
+	if len(cfg.Packages) != 1 || len(warnings) != 1 || !strings.Contains(warnings[0], "503") {
+		t.Fatalf("packages = %+v, warnings = %v", cfg.Packages, warnings)
+	}
+}
+
+func TestLoadDefaultsAndFiles_RemoteIncludeErrors(t *testing.T) {
+	u, _ := remoteInclude(t, "include: [local.yaml]\n")
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	for body, want := range map[string]string{
+		"include:\n  - url: " + u + "\n":                                  "set sha256 or refresh",
+		"include:\n  - url: http://example.com/a.yaml\n    refresh: 1h\n": "only https",
+		"include:\n  - url: " + u + "\n    refresh: 1h\n":                 "remote files can only include URLs",
+	} {
+		writeFile(t, main, body)
+		if _, err := LoadDefaultsAndFiles(nil, []string{main}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", body, want, err)
+		}
+	}
+}
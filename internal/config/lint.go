@@ -0,0 +1,566 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Lint rules reported in Issue.Rule.
+const (
+	RuleParse             = "parse"
+	RuleInclude           = "include"
+	RuleSchema            = "schema"
+	RuleDuplicate         = "duplicate"
+	RuleUndefinedSource   = "undefined-source"
+	RuleUnknownDependency = "unknown-dependency"
+	RuleUnknownPackage    = "unknown-package"
+	RuleMissingCommand    = "missing-command"
+	RulePlaceholder       = "placeholder"
+	RuleVersion           = "version"
+	RuleVersionRegex      = "version-regex"
+	RuleShellSyntax       = "shell-syntax"
+	RuleUnknownVariable   = "unknown-variable"
+	RuleSecret            = "secret"
+)
+
+const placeholderQuery = "{query}"
+
+// Issue is a problem found by Lint. Line and Column are 1-based and zero when
+// the problem cannot be tied to a position, as with schema errors.
+type Issue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	pos := i.File
+	if pos == "" {
+		pos = "config"
+	}
+	if i.Line > 0 {
+		pos += ":" + strconv.Itoa(i.Line)
+		if i.Column > 0 {
+			pos += ":" + strconv.Itoa(i.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s (%s)", pos, i.Message, i.Rule)
+}
+
+// lintSections maps the top-level keys holding entries to the kind used in
+// messages.
+var lintSections = []struct{ key, kind string }{
+	{"sources", "source"},
+	{"packages", "package"},
+	{"custom_packages", "custom_package"},
+	{"github_release_packages", "github_release_package"},
+}
+
+// lintCommands lists the command fields of each kind.
+var lintCommands = map[string][]string{
+	"source":                 {"install", "remove", "update", "search", "pre_update", "get_installed_version", "get_latest_version", "list_installed"},
+	"custom_package":         {"get_installed_version", "get_latest_version", "install", "update", "remove"},
+	"github_release_package": {"get_installed_version", "post_install", "remove"},
+}
+
+// lintEntry is one item of a top-level section together with its position.
+type lintEntry struct {
+	file   string
+	report bool
+	kind   string
+	name   string
+	node   *yaml.Node
+	// active is false for packages whose when conditions exclude this host.
+	active bool
+	// override is set for entries of the overrides section.
+	override bool
+	// windows is set when commands without a shell run under cmd: on a
+	// Windows host, or for entries whose when conditions only allow Windows.
+	windows bool
+}
+
+func (e lintEntry) issue(n *yaml.Node, rule, format string, args ...any) Issue {
+	return Issue{File: e.file, Line: n.Line, Column: n.Column, Rule: rule, Message: fmt.Sprintf(format, args...)}
+}
+
+// Lint checks the defaults and configuration files and reports every problem
+// found in files, sorted by position. Problems in the defaults are not
+// reported, but the sources and packages they define are known.
+func Lint(defaultsYAML []byte, files []string) []Issue {
+	var issues []Issue
+	var entries []lintEntry
+	var parts []Config
+	var profileRefs []lintEntry
+	h := host()
+	add := func(name string, b []byte, report bool) {
+		doc, err := parseDocument(name, b)
+		if err != nil {
+			if report {
+				issues = append(issues, yamlIssues(name, err)...)
+			}
+			return
+		}
+		var part Config
+		if err := doc.Decode(&part); err != nil {
+			if report {
+				issues = append(issues, yamlIssues(name, err)...)
+			}
+			return
+		}
+		parts = append(parts, FilterForHost(part, h))
+		entries = append(entries, collectEntries(name, doc, report, h)...)
+		if report {
+			profileRefs = append(profileRefs, collectProfileRefs(name, doc)...)
+			issues = append(issues, lintSecretCommand(name, doc)...)
+		}
+	}
+	if len(defaultsYAML) > 0 {
+		add("defaults", defaultsYAML, false)
+	}
+	order, err := expandIncludes(files)
+	if err != nil {
+		issues = append(issues, Issue{Rule: RuleInclude, Message: err.Error()})
+		for _, f := range sortedConfigFiles(files) {
+			order = append(order, configFile{Path: f})
+		}
+	}
+	for _, f := range order {
+		b, err := os.ReadFile(f.Path)
+		if err != nil {
+			issues = append(issues, Issue{File: f.Path, Rule: RuleParse, Message: err.Error()})
+			continue
+		}
+		add(f.name(), b, true)
+	}
+
+	merged := Config{}
+	for _, p := range parts {
+		merged = mergeConfig(merged, p)
+		// Unknown overrides are reported with their position below.
+		merged, _ = applyOverrides(merged, p.Overrides, nil)
+	}
+	vars, err := ResolveVars(merged)
+	if err != nil {
+		issues = append(issues, Issue{Rule: RuleUnknownVariable, Message: err.Error()})
+		vars = BuiltinVars("")
+	}
+
+	issues = append(issues, lintEntries(entries, vars)...)
+	issues = append(issues, lintProfiles(entries, profileRefs)...)
+	if resolved, err := ResolveExtends(merged); err == nil {
+		merged = resolved
+	} else if errors.Is(err, errSourceCycle) {
+		issues = append(issues, Issue{Rule: RuleUndefinedSource, Message: err.Error()})
+	}
+	if err := ValidateAgainstSchema(merged); err != nil {
+		issues = append(issues, Issue{Rule: RuleSchema, Message: err.Error()})
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return issues
+}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+): (.*)`)
+
+// yamlIssues turns a parse or decode error into one issue per reported line.
+func yamlIssues(file string, err error) []Issue {
+	msgs := []string{err.Error()}
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		msgs = te.Errors
+	}
+	var out []Issue
+	for _, msg := range msgs {
+		is := Issue{File: file, Rule: RuleParse, Message: strings.TrimPrefix(msg, "yaml: ")}
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			is.Line, _ = strconv.Atoi(m[1])
+			is.Message = m[2]
+		}
+		out = append(out, is)
+	}
+	return out
+}
+
+func collectEntries(file string, doc *yaml.Node, report bool, h Host) []lintEntry {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	out := sectionEntries(file, root, report, h, false)
+	if _, o := mappingValue(root, "overrides"); o != nil && o.Kind == yaml.MappingNode {
+		out = append(out, sectionEntries(file, o, report, h, true)...)
+	}
+	return out
+}
+
+func sectionEntries(file string, root *yaml.Node, report bool, h Host, override bool) []lintEntry {
+	var out []lintEntry
+	for _, sec := range lintSections {
+		if override && sec.kind == "source" {
+			continue
+		}
+		_, seq := mappingValue(root, sec.key)
+		if seq == nil || seq.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range seq.Content {
+			if item.Kind != yaml.MappingNode {
+				continue
+			}
+			e := lintEntry{file: file, report: report, kind: sec.kind, node: item, active: true, override: override, windows: h.OS == "windows"}
+			if _, v := mappingValue(item, "name"); v != nil {
+				e.name = v.Value
+			}
+			if _, v := mappingValue(item, "when"); v != nil {
+				var w When
+				e.active = v.Decode(&w) == nil && w.Matches(h)
+				e.windows = e.windows || windowsOnly(w)
+			}
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// collectProfileRefs returns one entry per package named in a profile; name
+// holds the profile and node the package name.
+func collectProfileRefs(file string, doc *yaml.Node) []lintEntry {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	_, profiles := mappingValue(doc.Content[0], "profiles")
+	if profiles == nil || profiles.Kind != yaml.MappingNode {
+		return nil
+	}
+	var out []lintEntry
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		list := profiles.Content[i+1]
+		if list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range list.Content {
+			out = append(out, lintEntry{file: file, report: true, kind: "profile", name: profiles.Content[i].Value, node: item})
+		}
+	}
+	return out
+}
+
+func lintProfiles(entries, refs []lintEntry) []Issue {
+	known := map[string]bool{}
+	for _, e := range entries {
+		if e.kind == "source" {
+			continue
+		}
+		if !e.override {
+			known[e.name] = true
+		}
+		if _, tags := mappingValue(e.node, "tags"); tags != nil && tags.Kind == yaml.SequenceNode {
+			for _, t := range tags.Content {
+				known[t.Value] = true
+			}
+		}
+	}
+	var issues []Issue
+	for _, r := range refs {
+		if !known[r.node.Value] {
+			issues = append(issues, r.issue(r.node, RuleUnknownPackage, "profile %q lists unknown package or tag %q", r.name, r.node.Value))
+		}
+	}
+	return issues
+}
+
+// mappingValue returns the key and value nodes of key in the mapping m.
+func mappingValue(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func lintEntries(entries []lintEntry, vars map[string]string) []Issue {
+	var issues []Issue
+	sources := map[string]bool{}
+	packages := map[string]bool{}
+	active := map[string]lintEntry{}
+	sourcesByFile := map[string]bool{}
+	sourceNodes := map[string]*yaml.Node{}
+	defined := map[string]bool{}
+	for _, e := range entries {
+		if e.override {
+			continue
+		}
+		if e.kind == "source" {
+			key := e.file + "\x00" + e.name
+			if sourcesByFile[key] && e.report {
+				issues = append(issues, e.issue(e.node, RuleDuplicate, "duplicate source %q", e.name))
+			}
+			sourcesByFile[key] = true
+			sources[e.name] = true
+			sourceNodes[e.name] = e.node
+			continue
+		}
+		packages[e.name] = true
+		defined[e.kind+"\x00"+e.name] = true
+		if !e.active {
+			continue
+		}
+		if prev, ok := active[e.name]; ok {
+			if e.report {
+				issues = append(issues, e.issue(e.node, RuleDuplicate, "duplicate package %q, first defined in %s", e.name, entryPos(prev)))
+			}
+			continue
+		}
+		active[e.name] = e
+	}
+
+	for _, e := range entries {
+		if !e.report {
+			continue
+		}
+		if e.kind == "source" {
+			if _, v := mappingValue(e.node, "extends"); v != nil && !sources[v.Value] {
+				issues = append(issues, e.issue(v, RuleUndefinedSource, "source %q extends undefined source %q", e.name, v.Value))
+			}
+		}
+		if e.override && !defined[e.kind+"\x00"+e.name] {
+			issues = append(issues, e.issue(e.node, RuleUnknownPackage, "override of unknown %s %q", e.kind, e.name))
+		}
+		if e.kind == "package" {
+			_, alts := mappingValue(e.node, "alternatives")
+			if _, v := mappingValue(e.node, "source"); v == nil {
+				if !e.override && alts == nil {
+					issues = append(issues, e.issue(e.node, RuleUndefinedSource, "package %q has no source", e.name))
+				}
+			} else if !sources[v.Value] {
+				issues = append(issues, e.issue(v, RuleUndefinedSource, "package %q uses undefined source %q", e.name, v.Value))
+			}
+			if alts != nil && alts.Kind == yaml.SequenceNode {
+				for _, a := range alts.Content {
+					if _, v := mappingValue(a, "source"); v != nil && !sources[v.Value] {
+						issues = append(issues, e.issue(v, RuleUndefinedSource, "package %q alternative uses undefined source %q", e.name, v.Value))
+					}
+				}
+			}
+		}
+		if _, deps := mappingValue(e.node, "depends_on"); deps != nil && deps.Kind == yaml.SequenceNode {
+			for _, d := range deps.Content {
+				if !packages[d.Value] {
+					issues = append(issues, e.issue(d, RuleUnknownDependency, "%s %q depends on unknown package %q", e.kind, e.name, d.Value))
+				}
+			}
+		}
+		switch {
+		case e.override:
+		case e.kind == "custom_package":
+			if cmd, _ := commandNode(e.node, "get_installed_version"); cmd == nil {
+				issues = append(issues, e.issue(e.node, RuleMissingCommand, "custom_package %q has no get_installed_version", e.name))
+			}
+		case e.kind == "github_release_package":
+			if cmd, _ := commandNode(e.node, "remove"); cmd == nil {
+				issues = append(issues, e.issue(e.node, RuleMissingCommand, "github_release_package %q has no remove", e.name))
+			}
+		}
+		if _, v := mappingValue(e.node, "version"); v != nil && v.Kind == yaml.ScalarNode {
+			se := e
+			if _, sv := mappingValue(e.node, "version_scheme"); e.override && sv == nil {
+				if base, ok := active[e.name]; ok && base.kind == e.kind {
+					se = base
+				}
+			}
+			for _, scheme := range lintSchemes(se, sourceNodes) {
+				if err := validateVersionConstraint(e.kind, e.name, v.Value, scheme); err != nil {
+					issues = append(issues, e.issue(v, RuleVersion, "%s", err.Error()))
+					break
+				}
+			}
+		}
+		if _, v := mappingValue(e.node, "version_regex"); v != nil {
+			if err := validateVersionRegex(e.kind, e.name, v.Value); err != nil {
+				issues = append(issues, e.issue(v, RuleVersionRegex, "%s", err.Error()))
+			}
+		}
+		for _, field := range lintCommands[e.kind] {
+			cmd, text := commandNode(e.node, field)
+			if cmd == nil {
+				continue
+			}
+			shell := commandShell(e.node, field)
+			if shell == "" && e.windows {
+				shell = "cmd"
+			}
+			issues = append(issues, lintCommand(e, field, cmd, text, shell, vars)...)
+		}
+		if _, v := mappingValue(e.node, "asset_pattern"); v != nil {
+			if u := unknownVars(v.Value, vars); len(u) > 0 {
+				issues = append(issues, e.issue(v, RuleUnknownVariable, "%s %q asset_pattern uses unknown variable {%s}; write {{%s}} for literal braces", e.kind, e.name, strings.Join(u, "}, {"), u[0]))
+			}
+		}
+	}
+	return issues
+}
+
+// lintSchemes returns the version schemes the version field of e is checked
+// against, like packageSchemes but from the nodes of the entry and of the
+// sources, following extends.
+func lintSchemes(e lintEntry, sources map[string]*yaml.Node) []string {
+	scalar := func(n *yaml.Node, key string) string {
+		if _, v := mappingValue(n, key); v != nil && v.Kind == yaml.ScalarNode {
+			return v.Value
+		}
+		return ""
+	}
+	sourceScheme := func(name string) string {
+		seen := map[string]bool{}
+		for n := sources[name]; n != nil && !seen[name]; n = sources[name] {
+			seen[name] = true
+			if s := scalar(n, "version_scheme"); s != "" {
+				return s
+			}
+			name = scalar(n, "extends")
+		}
+		return ""
+	}
+	if s := scalar(e.node, "version_scheme"); s != "" || e.kind != "package" {
+		return []string{s}
+	}
+	if src := scalar(e.node, "source"); src != "" {
+		return []string{sourceScheme(src)}
+	}
+	_, alts := mappingValue(e.node, "alternatives")
+	if alts == nil || alts.Kind != yaml.SequenceNode {
+		return []string{""}
+	}
+	var out []string
+	for _, a := range alts.Content {
+		out = append(out, sourceScheme(scalar(a, "source")))
+	}
+	return out
+}
+
+// lintSecretCommand reports a secret_command that references secrets, as it
+// is what resolves them.
+func lintSecretCommand(file string, doc *yaml.Node) []Issue {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	n, text := commandNode(doc.Content[0], "secret_command")
+	if n == nil || len(SecretRefs(text)) == 0 {
+		return nil
+	}
+	return []Issue{{File: file, Line: n.Line, Column: n.Column, Rule: RuleSecret, Message: "secret_command cannot reference secrets"}}
+}
+
+func entryPos(e lintEntry) string {
+	return fmt.Sprintf("%s:%d", e.file, e.node.Line)
+}
+
+// commandNode returns the scalar holding the command of field, which may be
+// written as a string or as a mapping with a command key.
+func commandNode(m *yaml.Node, field string) (*yaml.Node, string) {
+	_, v := mappingValue(m, field)
+	if v != nil && v.Kind == yaml.MappingNode {
+		_, v = mappingValue(v, "command")
+	}
+	if v == nil || v.Kind != yaml.ScalarNode || strings.TrimSpace(v.Value) == "" {
+		return nil, ""
+	}
+	return v, v.Value
+}
+
+// windowsOnly reports whether w restricts an entry to Windows hosts.
+func windowsOnly(w When) bool {
+	for _, o := range w.OS {
+		if !strings.EqualFold(o, "windows") {
+			return false
+		}
+	}
+	return len(w.OS) > 0
+}
+
+// commandShell returns the shell option of the command in field, if any.
+func commandShell(m *yaml.Node, field string) string {
+	_, v := mappingValue(m, field)
+	if v == nil || v.Kind != yaml.MappingNode {
+		return ""
+	}
+	if _, sh := mappingValue(v, "shell"); sh != nil {
+		return sh.Value
+	}
+	return ""
+}
+
+func lintCommand(e lintEntry, field string, n *yaml.Node, text, shell string, vars map[string]string) []Issue {
+	var issues []Issue
+	if u := unknownVars(text, vars); len(u) > 0 {
+		issues = append(issues, e.issue(n, RuleUnknownVariable, "%s %q %s command uses unknown variable {%s}; write {{%s}} for literal braces", e.kind, e.name, field, strings.Join(u, "}, {"), u[0]))
+	}
+	if field != "search" && HasRef(text, placeholderQuery) {
+		issues = append(issues, e.issue(n, RulePlaceholder, "%s %q %s command uses %s, which is only available in search commands", e.kind, e.name, field, placeholderQuery))
+	}
+	if err := validateCommandPlaceholders(e.kind, e.name, field, Command{Command: text}); err != nil {
+		issues = append(issues, e.issue(n, RulePlaceholder, "%s", strings.TrimPrefix(err.Error(), "invalid placeholders: ")))
+	}
+	if err := parseShell(text, shell); err != nil {
+		is := e.issue(n, RuleShellSyntax, "%s %q %s command: %s", e.kind, e.name, field, err.Error())
+		var pe syntax.ParseError
+		if errors.As(err, &pe) {
+			is.Message = fmt.Sprintf("%s %q %s command: %s", e.kind, e.name, field, pe.Text)
+			is.Line, is.Column = commandPosition(n, int(pe.Pos.Line()), int(pe.Pos.Col()))
+		}
+		issues = append(issues, is)
+	}
+	return issues
+}
+
+// parseShell parses a command the way shell would run it; bash is the
+// default. PowerShell and cmd commands are not checked. Placeholders are plain words
+// to the parser and need no substitution; secret references become the
+// variables they are run as.
+func parseShell(text, shell string) error {
+	text = ReplaceSecretRefs(text, func(string) string { return "${GOPAK_SECRET}" })
+	lang := syntax.LangBash
+	switch shell {
+	case "pwsh", "cmd":
+		return nil
+	case "sh":
+		lang = syntax.LangPOSIX
+	case "zsh":
+		lang = syntax.LangZsh
+	}
+	_, err := syntax.NewParser(syntax.Variant(lang)).Parse(strings.NewReader(text), "")
+	return err
+}
+
+// commandPosition maps a line and column inside the command text to the file.
+// Block scalars start on the line after their indicator; for other styles
+// only the line is mapped, as quoting shifts columns.
+func commandPosition(n *yaml.Node, line, col int) (int, int) {
+	if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return n.Line + line, 0
+	}
+	if line == 1 && n.Style == 0 {
+		return n.Line, n.Column + col - 1
+	}
+	return n.Line + line - 1, 0
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/assets"
+)
+
+func TestLint_ReportsEveryProblemWithPosition(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "tools.yaml")
+	os.WriteFile(f, []byte(`packages:
+  - name: ripgrep
+    source: aptt
+custom_packages:
+  - name: tool
+    depends_on: [ripgrep, missing]
+    install: "echo {query}"
+  - name: broken
+    get_installed_version: broken --version
+    install: |
+      if true; then
+        echo hi
+github_release_packages:
+  - name: lazygit
+    repo: jesseduffield/lazygit
+    asset_pattern: lazygit
+`), 0o644)
+
+	issues := Lint(assets.DefaultSources, []string{f})
+	want := []struct {
+		line int
+		rule string
+	}{
+		{3, RuleUndefinedSource},
+		{5, RuleMissingCommand},
+		{6, RuleUnknownDependency},
+		{7, RulePlaceholder},
+		{11, RuleShellSyntax},
+		{14, RuleMissingCommand},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("got %d issues, want %d: %v", len(issues), len(want), issues)
+	}
+	for i, w := range want {
+		if issues[i].File != f || issues[i].Line != w.line || issues[i].Rule != w.rule {
+			t.Errorf("issue %d = %s, want line %d rule %s", i, issues[i], w.line, w.rule)
+		}
+	}
+	if !strings.Contains(issues[2].Message, `"missing"`) {
+		t.Errorf("dependency issue should name the package: %s", issues[2])
+	}
+}
+
+func TestLint_CleanConfig(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "tools.yaml")
+	os.WriteFile(f, []byte(`packages:
+  - name: ripgrep
+    source: apt
+custom_packages:
+  - name: tool
+    depends_on: [ripgrep]
+    get_installed_version: "tool --version 2>/dev/null | head -n1"
+    install:
+      command: |
+        case "$latest_version" in
+          *) echo "$latest_version" ;;
+        esac
+      require_root: true
+`), 0o644)
+	if issues := Lint(assets.DefaultSources, []string{f}); len(issues) != 0 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_ParseError(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "bad.yaml")
+	os.WriteFile(f, []byte("packages:\n  - name: a\n\tsource: apt\n"), 0o644)
+	issues := Lint(nil, []string{f})
+	if len(issues) != 1 || issues[0].Rule != RuleParse || issues[0].Line != 2 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_ProfileUnknownPackage(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "profiles.yaml")
+	os.WriteFile(f, []byte(`packages:
+  - name: git
+    source: apt
+    tags: [cli]
+profiles:
+  server: [git, cli, htop]
+`), 0o644)
+	issues := Lint(assets.DefaultSources, []string{f})
+	if len(issues) != 1 || issues[0].Rule != RuleUnknownPackage || issues[0].Line != 6 || !strings.Contains(issues[0].Message, `"htop"`) {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_Extends(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "extends.yaml")
+	writeFile(t, f, `sources:
+  - name: apt-backports
+    extends: apt
+  - name: broken
+    extends: missing
+`)
+	issues := Lint(assets.DefaultSources, []string{f})
+	if len(issues) != 1 || issues[0].Rule != RuleUndefinedSource || issues[0].Line != 5 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+	writeFile(t, f, `sources:
+  - name: a
+    extends: b
+  - name: b
+    extends: a
+`)
+	issues = Lint(nil, []string{f})
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, "cycle") {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "overrides.yaml")
+	writeFile(t, f, `packages:
+  - name: git
+    source: apt
+overrides:
+  packages:
+    - name: git
+      depends_on: [curl]
+    - name: gti
+      executable: git
+`)
+	issues := Lint(assets.DefaultSources, []string{f})
+	if len(issues) != 2 || issues[0].Rule != RuleUnknownDependency || issues[0].Line != 7 || issues[1].Rule != RuleUnknownPackage || issues[1].Line != 8 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_SecretCommand(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "secrets.yaml")
+	writeFile(t, f, `secret_command: pass show ${secret:master}/{name}
+custom_packages:
+  - name: tool
+    get_installed_version: tool --version
+    install:
+      command: 'curl -H "Authorization: Bearer ${secret:artifacts}" https://example.com/tool'
+      shell: sh
+`)
+	issues := Lint(nil, []string{f})
+	if len(issues) != 1 || issues[0].Rule != RuleSecret || issues[0].Line != 1 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_CommandShell(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "shells.yaml")
+	writeFile(t, f, `custom_packages:
+  - name: winget-tool
+    get_installed_version:
+      command: "(Get-Command tool).Version.ToString()"
+      shell: pwsh
+    install:
+      command: "targets=(all install); make install"
+      shell: sh
+      timeout: 10m
+      env: { PREFIX: /usr/local }
+`)
+	issues := Lint(nil, []string{f})
+	if len(issues) != 1 || issues[0].Rule != RuleShellSyntax || issues[0].Line != 7 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_WindowsCommandsNotParsedAsBash(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "windows.yaml")
+	writeFile(t, f, `custom_packages:
+  - name: win-tool
+    when: {os: windows}
+    get_installed_version: 'if exist "C:\tool" (echo 1.0) else (exit /b 1)'
+  - name: posix-tool
+    get_installed_version: 'if exist "C:\tool" (echo 1.0) else (exit /b 1)'
+`)
+	issues := Lint(nil, []string{f})
+	if len(issues) != 1 || issues[0].Rule != RuleShellSyntax || issues[0].Line != 6 {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+
+	prev := host
+	host = func() Host { return Host{OS: "windows"} }
+	defer func() { host = prev }()
+	if issues := Lint(nil, []string{f}); len(issues) != 0 {
+		t.Fatalf("on Windows the default shell is cmd: %v", issues)
+	}
+}
+
+func TestLint_Alternatives(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "alternatives.yaml")
+	writeFile(t, f, `packages:
+  - name: fd
+    alternatives:
+      - source: apt
+        name: fd-find
+      - source: zypper
+      - github: sharkdp/fd
+        asset_pattern: "fd-.*-x86_64-unknown-linux-gnu.tar.gz"
+`)
+	issues := Lint(assets.DefaultSources, []string{f})
+	if len(issues) != 1 || issues[0].Rule != RuleUndefinedSource || issues[0].Line != 6 || !strings.Contains(issues[0].Message, `"zypper"`) {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
+
+func TestLint_VersionConstraint(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "versions.yaml")
+	writeFile(t, f, `sources:
+  - name: base
+    extends: apt
+    version_scheme: semver
+  - name: child
+    extends: base
+packages:
+  - name: git
+    source: child
+    version: ">=1.2.0"
+  - name: htop
+    source: apt
+    version: ">=1:3.0"
+  - name: jq
+    source: child
+    version: ">=1:2"
+  - name: fd
+    source: apt
+    version: ">=2.x.y garbage !!"
+custom_packages:
+  - name: tool
+    get_installed_version: tool --version
+    version: "^1.2"
+overrides:
+  packages:
+    - name: git
+      version: "<1:3"
+`)
+	issues := Lint(assets.DefaultSources, []string{f})
+	var lines []int
+	for _, is := range issues {
+		if is.Rule != RuleVersion {
+			t.Fatalf("unexpected issue: %v", is)
+		}
+		lines = append(lines, is.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{16, 19, 27}) {
+		t.Fatalf("unexpected issues: %v", issues)
+	}
+}
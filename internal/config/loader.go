@@ -1,44 +1,76 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"sort"
 	"strings"
-
-	"gopkg.in/yaml.v3"
 )
 
 var current Config
 
+// host is matched against the when conditions of packages while loading.
+var host = CurrentHost
+
 func Get() Config { return current }
 
 func LoadFromFiles(files []string) (Config, error) {
 	combined := Config{}
 	seen := map[string]string{}
-	for _, f := range sortedYAML(files) {
-		b, err := os.ReadFile(f)
+	defined := map[string]bool{}
+	h := host()
+	order, err := expandIncludes(files)
+	if err != nil {
+		return Config{}, err
+	}
+	for _, f := range order {
+		b, err := os.ReadFile(f.Path)
 		if err != nil {
-			return Config{}, err
+			return Config{}, fmt.Errorf("%s: %w", f.label(), err)
 		}
-		var part Config
-		if err := yaml.Unmarshal(b, &part); err != nil {
-			return Config{}, fmt.Errorf("%s: %w", f, err)
+		part, err := decodeConfig(f.Path, b)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", f.label(), err)
 		}
-		if err := checkPkgDuplicatesWithFiles(seen, part, f); err != nil {
+		for _, n := range packageNames(part) {
+			defined[n] = true
+		}
+		part = FilterForHost(part, h)
+		if err := checkPkgDuplicatesWithFiles(seen, part, f.label()); err != nil {
 			return Config{}, err
 		}
 		combined.Sources = append(combined.Sources, part.Sources...)
 		combined.Packages = append(combined.Packages, part.Packages...)
 		combined.CustomPackages = append(combined.CustomPackages, part.CustomPackages...)
 		combined.GithubReleasePackages = append(combined.GithubReleasePackages, part.GithubReleasePackages...)
+		combined.Profiles = mergeProfiles(combined.Profiles, part.Profiles)
+		combined.Vars = mergeVars(combined.Vars, part.Vars)
+		combined, err = applyOverrides(combined, part.Overrides, inactiveNames(defined, seen))
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", f.label(), err)
+		}
 	}
 	if err := ValidateNoDuplicates(combined); err != nil {
 		return Config{}, err
 	}
+	combined, err = ResolveExtends(combined)
+	if err != nil {
+		return Config{}, err
+	}
 	if err := ValidatePlaceholders(combined); err != nil {
 		return Config{}, err
 	}
+	if err := ValidateVersionRegex(combined); err != nil {
+		return Config{}, err
+	}
+	if err := ValidateVersionConstraints(combined); err != nil {
+		return Config{}, err
+	}
+	if err := ValidateVars(combined); err != nil {
+		return Config{}, err
+	}
 	current = combined
 	return combined, nil
 }
@@ -46,10 +78,17 @@ func LoadFromFiles(files []string) (Config, error) {
 func LoadDefaultsAndFiles(defaultsYAML []byte, files []string) (Config, error) {
 	var base Config
 	if len(defaultsYAML) > 0 {
-		if err := yaml.Unmarshal(defaultsYAML, &base); err != nil {
+		var err error
+		if base, err = decodeConfig(DefaultsFile, defaultsYAML); err != nil {
 			return Config{}, fmt.Errorf("defaults: %w", err)
 		}
 	}
+	h := host()
+	defined := map[string]bool{}
+	for _, n := range packageNames(base) {
+		defined[n] = true
+	}
+	base = FilterForHost(base, h)
 	merged := base
 	seen := map[string]string{}
 	for _, p := range base.Packages {
@@ -61,27 +100,52 @@ func LoadDefaultsAndFiles(defaultsYAML []byte, files []string) (Config, error) {
 	for _, p := range base.GithubReleasePackages {
 		seen[p.Name] = "defaults"
 	}
-	for _, f := range sortedYAML(files) {
-		b, err := os.ReadFile(f)
+	order, err := expandIncludes(files)
+	if err != nil {
+		return Config{}, err
+	}
+	for _, f := range order {
+		b, err := os.ReadFile(f.Path)
 		if err != nil {
-			return Config{}, err
+			return Config{}, fmt.Errorf("%s: %w", f.label(), err)
+		}
+		part, err := decodeConfig(f.Path, b)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", f.label(), err)
 		}
-		var part Config
-		if err := yaml.Unmarshal(b, &part); err != nil {
-			return Config{}, fmt.Errorf("%s: %w", f, err)
+		for _, n := range packageNames(part) {
+			defined[n] = true
 		}
-		if err := checkPkgDuplicatesWithFiles(seen, part, f); err != nil {
+		part = FilterForHost(part, h)
+		if err := checkPkgDuplicatesWithFiles(seen, part, f.label()); err != nil {
 			return Config{}, err
 		}
 		merged = mergeConfig(merged, part)
+		merged, err = applyOverrides(merged, part.Overrides, inactiveNames(defined, seen))
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", f.label(), err)
+		}
 	}
 	if err := ValidateNoDuplicates(merged); err != nil {
 		return Config{}, err
 	}
+	merged, err = ResolveExtends(merged)
+	if err != nil {
+		return Config{}, err
+	}
 	if err := ValidatePlaceholders(merged); err != nil {
 		return Config{}, err
 	}
-	merged, err := AddRuntimeDefaults(merged)
+	if err := ValidateVersionRegex(merged); err != nil {
+		return Config{}, err
+	}
+	if err := ValidateVersionConstraints(merged); err != nil {
+		return Config{}, err
+	}
+	if err := ValidateVars(merged); err != nil {
+		return Config{}, err
+	}
+	merged, err = AddRuntimeDefaults(merged)
 	if err != nil {
 		return Config{}, err
 	}
@@ -119,11 +183,11 @@ func ValidateNoDuplicates(cfg Config) error {
 	return nil
 }
 
-func sortedYAML(files []string) []string {
+// sortedConfigFiles returns the configuration files among files, sorted.
+func sortedConfigFiles(files []string) []string {
 	out := make([]string, 0, len(files))
 	for _, f := range files {
-		lf := strings.ToLower(f)
-		if strings.HasSuffix(lf, ".yaml") || strings.HasSuffix(lf, ".yml") {
+		if IsConfigFile(f) {
 			out = append(out, f)
 		}
 	}
@@ -166,7 +230,55 @@ func mergeConfig(base, overlay Config) Config {
 		execCacheTTL = overlay.ExecCacheTTL
 	}
 
-	return Config{Sources: sources, Packages: packages, CustomPackages: custom, GithubReleasePackages: gh, ExecCacheTTL: execCacheTTL}
+	out := Config{Sources: sources, Packages: packages, CustomPackages: custom, GithubReleasePackages: gh, ExecCacheTTL: execCacheTTL, Profiles: mergeProfiles(base.Profiles, overlay.Profiles), Vars: mergeVars(base.Vars, overlay.Vars)}
+	out.SecretFile, out.SecretIdentity, out.SecretCommand = base.SecretFile, base.SecretIdentity, base.SecretCommand
+	if overlay.SecretFile != "" {
+		out.SecretFile = overlay.SecretFile
+	}
+	if overlay.SecretIdentity != "" {
+		out.SecretIdentity = overlay.SecretIdentity
+	}
+	if overlay.SecretCommand != nil {
+		out.SecretCommand = overlay.SecretCommand
+	}
+	return out
+}
+
+// mergeVars combines vars from several files; later files win.
+func mergeVars(base, overlay map[string]string) map[string]string {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+	out := map[string]string{}
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range overlay {
+		out[k] = v
+	}
+	return out
+}
+
+// mergeProfiles combines profiles defined in several files; a profile named
+// in both gets the entries of each, without repeats.
+func mergeProfiles(base, overlay map[string][]string) map[string][]string {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+	out := map[string][]string{}
+	for _, profiles := range []map[string][]string{base, overlay} {
+		for name, entries := range profiles {
+			for _, e := range entries {
+				if !slices.Contains(out[name], e) {
+					out[name] = append(out[name], e)
+				}
+			}
+			if out[name] == nil {
+				out[name] = []string{}
+			}
+		}
+	}
+	return out
 }
 
 func mergeSource(a, b Source) Source {
@@ -181,30 +293,170 @@ func mergeSource(a, b Source) Source {
 	out.PreUpdate = mergeCommand(out.PreUpdate, b.PreUpdate)
 	out.GetInstalledVersion = mergeCommand(out.GetInstalledVersion, b.GetInstalledVersion)
 	out.GetLatestVersion = mergeCommand(out.GetLatestVersion, b.GetLatestVersion)
+	out.ListInstalled = mergeCommand(out.ListInstalled, b.ListInstalled)
+	if b.VersionScheme != "" {
+		out.VersionScheme = b.VersionScheme
+	}
+	if b.VersionRegex != "" {
+		out.VersionRegex = b.VersionRegex
+	}
+	if b.Extends != "" {
+		out.Extends = b.Extends
+	}
+	return out
+}
+
+var errSourceCycle = errors.New("source inheritance cycle")
+
+// ResolveExtends replaces every source that extends another with the base
+// source overlaid by the fields it sets itself. Bases may extend further
+// sources; a source that ends up extending itself is an error.
+func ResolveExtends(cfg Config) (Config, error) {
+	byName := map[string]Source{}
+	for _, s := range cfg.Sources {
+		byName[s.Name] = s
+	}
+	resolved := map[string]Source{}
+	var resolve func(name string, chain []string) (Source, error)
+	resolve = func(name string, chain []string) (Source, error) {
+		if s, ok := resolved[name]; ok {
+			return s, nil
+		}
+		for i, c := range chain {
+			if c == name {
+				return Source{}, fmt.Errorf("%w: %s", errSourceCycle, strings.Join(append(chain[i:], name), " -> "))
+			}
+		}
+		s := byName[name]
+		if s.Extends == "" {
+			resolved[name] = s
+			return s, nil
+		}
+		if _, ok := byName[s.Extends]; !ok {
+			return Source{}, fmt.Errorf("source %q extends unknown source %q", name, s.Extends)
+		}
+		base, err := resolve(s.Extends, append(chain, name))
+		if err != nil {
+			return Source{}, err
+		}
+		out := mergeSource(base, s)
+		out.Name = s.Name
+		resolved[name] = out
+		return out, nil
+	}
+	sources := make([]Source, 0, len(cfg.Sources))
+	for _, s := range cfg.Sources {
+		r, err := resolve(s.Name, nil)
+		if err != nil {
+			return Config{}, err
+		}
+		sources = append(sources, r)
+	}
+	cfg.Sources = sources
+	return cfg, nil
+}
+
+// mergePackage overlays the fields set in b on a. An empty depends_on list
+// clears the dependencies; the name and when condition of a are kept. A
+// source replaces alternatives and the other way round.
+func mergePackage(a, b Package) Package {
+	out := a
+	if b.Source != "" {
+		out.Source, out.Alternatives = b.Source, nil
+	}
+	if b.Alternatives != nil {
+		out.Source, out.Alternatives = "", b.Alternatives
+	}
+	if b.DependsOn != nil {
+		out.DependsOn = b.DependsOn
+	}
+	if b.Tags != nil {
+		out.Tags = b.Tags
+	}
+	if b.Version != "" {
+		out.Version = b.Version
+	}
+	if b.VersionScheme != "" {
+		out.VersionScheme = b.VersionScheme
+	}
+	if b.VersionRegex != "" {
+		out.VersionRegex = b.VersionRegex
+	}
+	if b.Executable.IsSet() {
+		out.Executable = b.Executable
+	}
 	return out
 }
 
 func mergeCustomPackage(a, b CustomPackage) CustomPackage {
 	out := a
-	if len(b.DependsOn) > 0 {
+	if b.DependsOn != nil {
 		out.DependsOn = b.DependsOn
 	}
+	if b.Tags != nil {
+		out.Tags = b.Tags
+	}
+	if b.Version != "" {
+		out.Version = b.Version
+	}
+	if b.VersionScheme != "" {
+		out.VersionScheme = b.VersionScheme
+	}
+	if b.VersionRegex != "" {
+		out.VersionRegex = b.VersionRegex
+	}
+	if b.Executable.IsSet() {
+		out.Executable = b.Executable
+	}
 	out.GetLatestVersion = mergeCommand(out.GetLatestVersion, b.GetLatestVersion)
 	out.GetInstalledVersion = mergeCommand(out.GetInstalledVersion, b.GetInstalledVersion)
 	out.Remove = mergeCommand(out.Remove, b.Remove)
 	out.Install = mergeCommand(out.Install, b.Install)
+	out.Update = mergeCommand(out.Update, b.Update)
 	return out
 }
 
-func mergeCommand(a, b Command) Command {
+func mergeGithubReleasePackage(a, b GithubReleasePackage) GithubReleasePackage {
 	out := a
-	if b.Command != "" {
-		out.Command = b.Command
+	if b.DependsOn != nil {
+		out.DependsOn = b.DependsOn
+	}
+	if b.Tags != nil {
+		out.Tags = b.Tags
 	}
-	out.RequireRoot = b.RequireRoot
+	if b.Version != "" {
+		out.Version = b.Version
+	}
+	if b.VersionScheme != "" {
+		out.VersionScheme = b.VersionScheme
+	}
+	if b.VersionRegex != "" {
+		out.VersionRegex = b.VersionRegex
+	}
+	if b.Executable.IsSet() {
+		out.Executable = b.Executable
+	}
+	if b.Repo != "" {
+		out.Repo = b.Repo
+	}
+	if b.AssetPattern != "" {
+		out.AssetPattern = b.AssetPattern
+	}
+	out.GetInstalledVersion = mergeCommand(out.GetInstalledVersion, b.GetInstalledVersion)
+	out.PostInstall = mergeCommand(out.PostInstall, b.PostInstall)
+	out.Remove = mergeCommand(out.Remove, b.Remove)
 	return out
 }
 
+// mergeCommand overlays b on a. A command that is not set in b keeps a,
+// including whether it requires root.
+func mergeCommand(a, b Command) Command {
+	if b.Command == "" {
+		return a
+	}
+	return b
+}
+
 func checkPkgDuplicatesWithFiles(seen map[string]string, part Config, file string) error {
 	local := map[string]struct{}{}
 	for _, p := range part.Packages {
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -252,3 +253,186 @@ func TestLoadFromFiles_DuplicatePackageAcrossKinds(t *testing.T) {
 		t.Fatalf("expected duplicate error")
 	}
 }
+
+func TestLoadDefaultsAndFiles_MergesProfiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	os.WriteFile(a, []byte("profiles:\n  work: [git, slack]\n  server: [htop]\n"), 0o644)
+	os.WriteFile(b, []byte("profiles:\n  work: [git, zoom]\n"), 0o644)
+	cfg, err := LoadDefaultsAndFiles(nil, []string{a, b})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := strings.Join(cfg.Profiles["work"], ","); got != "git,slack,zoom" {
+		t.Fatalf("work profile = %s", got)
+	}
+	if got := strings.Join(cfg.Profiles["server"], ","); got != "htop" {
+		t.Fatalf("server profile = %s", got)
+	}
+}
+
+func TestLoadDefaultsAndFiles_Extends(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "user.yaml")
+	writeFile(t, f, `sources:
+  - name: apt-backports
+    extends: apt
+    install:
+      command: "apt install -t bookworm-backports {package_list}"
+      require_root: true
+  - name: apt-backports-quiet
+    extends: apt-backports
+    search:
+      command: "apt search -q {query}"
+packages:
+  - name: git
+    source: apt-backports-quiet
+`)
+	cfg, err := LoadDefaultsAndFiles(assets.DefaultSources, []string{f})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	by := map[string]Source{}
+	for _, s := range cfg.Sources {
+		by[s.Name] = s
+	}
+	apt, child := by["apt"], by["apt-backports-quiet"]
+	if child.Type != apt.Type {
+		t.Fatalf("type not inherited: %q", child.Type)
+	}
+	if child.Install.Command != "apt install -t bookworm-backports {package_list}" || !child.Install.RequireRoot {
+		t.Fatalf("install not inherited from the parent: %+v", child.Install)
+	}
+	if child.Search.Command != "apt search -q {query}" {
+		t.Fatalf("search not overridden: %s", child.Search.Command)
+	}
+	if !reflect.DeepEqual(child.Remove, apt.Remove) || !reflect.DeepEqual(child.GetLatestVersion, apt.GetLatestVersion) {
+		t.Fatalf("commands not inherited from apt: %+v", child)
+	}
+	if !reflect.DeepEqual(by["apt-backports"].Search, apt.Search) {
+		t.Fatalf("override leaked into the parent")
+	}
+}
+
+func TestLoadFromFiles_ExtendsErrors(t *testing.T) {
+	dir := t.TempDir()
+	cycle := filepath.Join(dir, "cycle.yaml")
+	writeFile(t, cycle, `sources:
+  - name: a
+    type: package_manager
+    extends: b
+  - name: b
+    extends: a
+`)
+	if _, err := LoadFromFiles([]string{cycle}); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	unknown := filepath.Join(dir, "unknown.yaml")
+	writeFile(t, unknown, `sources:
+  - name: a
+    extends: nope
+`)
+	if _, err := LoadFromFiles([]string{unknown}); err == nil || !strings.Contains(err.Error(), `unknown source "nope"`) {
+		t.Fatalf("expected unknown source error, got %v", err)
+	}
+}
+
+func TestLoadDefaultsAndFiles_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared.yaml")
+	writeFile(t, shared, `packages:
+  - name: git
+    source: apt
+    depends_on: [curl]
+  - name: curl
+    source: apt
+custom_packages:
+  - name: tool
+    get_installed_version: tool --version
+    install: install-tool
+    remove: rm tool
+github_release_packages:
+  - name: gh
+    repo: cli/cli
+    asset_pattern: gh_*_linux_amd64.tar.gz
+    remove: rm gh
+`)
+	local := filepath.Join(dir, "zz-local.yaml")
+	writeFile(t, local, `overrides:
+  packages:
+    - name: git
+      depends_on: []
+      executable: git
+  custom_packages:
+    - name: tool
+      remove:
+        command: rm -f /opt/tool
+        require_root: true
+  github_release_packages:
+    - name: gh
+      asset_pattern: gh_*_linux_arm64.tar.gz
+    - name: gh
+      version: "2.40.0"
+      when:
+        os: plan9
+`)
+	cfg, err := LoadDefaultsAndFiles(assets.DefaultSources, []string{local, shared})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for _, p := range cfg.Packages {
+		if p.Name == "git" && (len(p.DependsOn) != 0 || p.Executable.Binary() != "git" || p.Source != "apt") {
+			t.Fatalf("git not patched: %+v", p)
+		}
+	}
+	tool := cfg.CustomPackages[0]
+	if tool.Remove.Command != "rm -f /opt/tool" || !tool.Remove.RequireRoot || tool.Install.Command != "install-tool" {
+		t.Fatalf("tool not patched: %+v", tool)
+	}
+	for _, gp := range cfg.GithubReleasePackages {
+		if gp.Name != "gh" {
+			continue
+		}
+		if gp.AssetPattern != "gh_*_linux_arm64.tar.gz" || gp.Repo != "cli/cli" {
+			t.Fatalf("gh not patched: %+v", gp)
+		}
+		if gp.Version != "" {
+			t.Fatalf("override for another host applied: %+v", gp)
+		}
+	}
+	if cfg.Overrides != nil {
+		t.Fatalf("overrides kept after applying them: %+v", cfg.Overrides)
+	}
+}
+
+func TestLoadFromFiles_OverrideErrors(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.yaml")
+	writeFile(t, f, `packages:
+  - name: git
+    source: apt
+overrides:
+  custom_packages:
+    - name: git
+      remove: rm git
+`)
+	_, err := LoadFromFiles([]string{f})
+	if err == nil || !strings.Contains(err.Error(), `override of unknown custom_package "git"`) || !strings.Contains(err.Error(), f) {
+		t.Fatalf("expected unknown override error, got %v", err)
+	}
+
+	writeFile(t, f, `packages:
+  - name: htop
+    source: apt
+    when:
+      os: plan9
+overrides:
+  packages:
+    - name: htop
+      source: pacman
+`)
+	if _, err := LoadFromFiles([]string{f}); err != nil {
+		t.Fatalf("override of a package for another host: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the configuration format written by this release. Files
+// without a version key are version 1.
+const CurrentVersion = 2
+
+// migration upgrades a document from version from to from+1 by editing its
+// node tree in place, so comments survive a rewrite.
+type migration struct {
+	from  int
+	apply func(root *yaml.Node)
+}
+
+var migrations = []migration{
+	{from: 1, apply: mergeDownloadIntoInstall},
+}
+
+// fileVersion returns the version declared by the root mapping of a document.
+func fileVersion(root *yaml.Node) (int, error) {
+	_, v := mappingValue(root, "version")
+	if v == nil {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v.Value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("line %d: version must be a positive integer, got %q", v.Line, v.Value)
+	}
+	return n, nil
+}
+
+// migrateDocument upgrades doc to CurrentVersion and reports whether it
+// changed. A document newer than CurrentVersion is an error.
+func migrateDocument(doc *yaml.Node) (bool, error) {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return false, nil
+	}
+	root := doc.Content[0]
+	v, err := fileVersion(root)
+	if err != nil {
+		return false, err
+	}
+	if v > CurrentVersion {
+		return false, fmt.Errorf("config version %d is newer than this gopak supports (%d); update gopak", v, CurrentVersion)
+	}
+	if v == CurrentVersion {
+		return false, nil
+	}
+	for _, m := range migrations {
+		if m.from >= v {
+			m.apply(root)
+		}
+	}
+	setVersion(root, CurrentVersion)
+	return true, nil
+}
+
+// setVersion sets the version key, adding it after $schema or first. A key
+// added first takes over the comment above the file's first key, so the
+// leading comment stays on top.
+func setVersion(root *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	if _, v := mappingValue(root, "version"); v != nil {
+		*v = *value
+		return
+	}
+	at := 0
+	if k, _ := mappingValue(root, "$schema"); k != nil {
+		at = 2
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if at == 0 && len(root.Content) > 0 {
+		key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+	}
+	root.Content = append(root.Content[:at], append([]*yaml.Node{key, value}, root.Content[at:]...)...)
+}
+
+// mergeDownloadIntoInstall folds the download step of version 1 custom
+// packages into install: the download runs first, and the merged command
+// requires root when either step did.
+func mergeDownloadIntoInstall(root *yaml.Node) {
+	_, seq := mappingValue(root, "custom_packages")
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range seq.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		dk, dv := mappingValue(item, "download")
+		if dk == nil {
+			continue
+		}
+		var download, install Command
+		_ = dv.Decode(&download)
+		_, iv := mappingValue(item, "install")
+		if iv != nil {
+			_ = iv.Decode(&install)
+		}
+		merged := Command{Command: download.Command, RequireRoot: download.RequireRoot || install.RequireRoot}
+		if install.Command != "" {
+			merged.Command = strings.TrimRight(download.Command, "\n") + "\n" + install.Command
+		}
+		var n yaml.Node
+		_ = n.Encode(merged)
+		if !merged.RequireRoot {
+			n = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: merged.Command}
+		}
+		if strings.Contains(merged.Command, "\n") {
+			setLiteral(&n)
+		}
+		var kept []*yaml.Node
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			k := item.Content[i]
+			switch k.Value {
+			case "download":
+				continue
+			case "install":
+				n.HeadComment, n.LineComment = iv.HeadComment, iv.LineComment
+				kept = append(kept, k, &n)
+				continue
+			}
+			kept = append(kept, k, item.Content[i+1])
+		}
+		if iv == nil {
+			kept = append(kept, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "install", HeadComment: dk.HeadComment}, &n)
+		}
+		item.Content = kept
+	}
+}
+
+// setLiteral writes the command of n as a literal block.
+func setLiteral(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode {
+		n.Style = yaml.LiteralStyle
+		return
+	}
+	if _, v := mappingValue(n, "command"); v != nil {
+		v.Style = yaml.LiteralStyle
+	}
+}
+
+// MigrateFile returns the content of the YAML file at path upgraded to
+// CurrentVersion, and whether it changed. Comments are kept; formatting is
+// normalised to two-space indentation.
+func MigrateFile(path string) ([]byte, []byte, bool, error) {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(old, &doc); err != nil {
+		return nil, nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	changed, err := migrateDocument(&doc)
+	if err != nil || !changed {
+		if err != nil {
+			err = fmt.Errorf("%s: %w", path, err)
+		}
+		return old, old, false, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, false, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, false, err
+	}
+	return old, buf.Bytes(), true, nil
+}
+
+// FilesToMigrate returns files together with the files they include, in load
+// order. Only local YAML files can be rewritten; JSON, TOML and remote files
+// are migrated in memory when loaded.
+func FilesToMigrate(files []string) (yamlFiles, other []string, err error) {
+	order, err := expandIncludes(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range order {
+		if f.URL != "" {
+			other = append(other, f.URL)
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(f.Path)) {
+		case ".json", ".toml":
+			other = append(other, f.Path)
+		default:
+			yamlFiles = append(yamlFiles, f.Path)
+		}
+	}
+	return yamlFiles, other, nil
+}
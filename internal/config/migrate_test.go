@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyCustom = `$schema: https://example.com/schema.json
+# tools built from source
+custom_packages:
+  - name: mytool # keep me
+    get_installed_version: mytool --version
+    download:
+      command: curl -fsSLo /tmp/mytool.tgz https://example.com/mytool.tgz
+    install:
+      command: tar -C /usr/local/bin -xzf /tmp/mytool.tgz mytool
+      require_root: true
+    remove: rm -f /usr/local/bin/mytool
+  - name: fetched
+    get_installed_version: fetched --version
+    download: curl -fsSLo ~/.local/bin/fetched https://example.com/fetched
+`
+
+func TestMigrateFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "a.yaml")
+	writeFile(t, f, legacyCustom)
+	old, migrated, changed, err := MigrateFile(f)
+	if err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+	if string(old) != legacyCustom {
+		t.Fatalf("old content altered")
+	}
+	want := `$schema: https://example.com/schema.json
+version: 2
+# tools built from source
+custom_packages:
+  - name: mytool # keep me
+    get_installed_version: mytool --version
+    install:
+      command: |-
+        curl -fsSLo /tmp/mytool.tgz https://example.com/mytool.tgz
+        tar -C /usr/local/bin -xzf /tmp/mytool.tgz mytool
+      require_root: true
+    remove: rm -f /usr/local/bin/mytool
+  - name: fetched
+    get_installed_version: fetched --version
+    install: curl -fsSLo ~/.local/bin/fetched https://example.com/fetched
+`
+	out := string(migrated)
+	if out != want {
+		t.Fatalf("migrated file:\n%s\nwant:\n%s", out, want)
+	}
+
+	writeFile(t, f, out)
+	if _, _, changed, err := MigrateFile(f); err != nil || changed {
+		t.Fatalf("current file: changed=%v err=%v", changed, err)
+	}
+}
+
+func TestLoadFromFiles_MigratesInMemory(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.yaml")
+	writeFile(t, f, legacyCustom)
+	cfg, err := LoadFromFiles([]string{f})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := ValidateAgainstSchema(cfg); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	tool := cfg.CustomPackages[0]
+	if !strings.HasPrefix(tool.Install.Command, "curl") || !strings.HasSuffix(tool.Install.Command, "mytool") || !tool.Install.RequireRoot {
+		t.Fatalf("install = %+v", tool.Install)
+	}
+	if b, _ := os.ReadFile(f); string(b) != legacyCustom {
+		t.Fatalf("loading must not rewrite the file")
+	}
+
+	writeFile(t, f, "version: 3\npackages: []\n")
+	if _, err := LoadFromFiles([]string{f}); err == nil || !strings.Contains(err.Error(), "newer than this gopak supports") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+}
+
+func TestMigrateFile_KeepsLeadingComment(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "a.yaml")
+	writeFile(t, f, "# top comment\n# second line\npackages:\n  - name: git\n    source: apt\n")
+	_, migrated, changed, err := MigrateFile(f)
+	if err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+	want := "# top comment\n# second line\nversion: 2\npackages:\n  - name: git\n    source: apt\n"
+	if string(migrated) != want {
+		t.Fatalf("migrated file:\n%s\nwant:\n%s", migrated, want)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import "fmt"
+
+// applyOverrides patches the packages of cfg with o. Overrides naming a
+// package in skip, such as one whose when conditions exclude this host, are
+// ignored; any other override without a package of its kind is an error. All
+// overrides that match are applied even when another one fails.
+func applyOverrides(cfg Config, o *Overrides, skip map[string]bool) (Config, error) {
+	if o == nil {
+		return cfg, nil
+	}
+	var firstErr error
+	missing := func(kind, name string) {
+		if !skip[name] && firstErr == nil {
+			firstErr = fmt.Errorf("override of unknown %s %q", kind, name)
+		}
+	}
+	pkgs := append([]Package(nil), cfg.Packages...)
+	for _, ov := range o.Packages {
+		i := indexByName(len(pkgs), func(i int) string { return pkgs[i].Name }, ov.Name)
+		if i < 0 {
+			missing("package", ov.Name)
+			continue
+		}
+		pkgs[i] = mergePackage(pkgs[i], ov)
+	}
+	custom := append([]CustomPackage(nil), cfg.CustomPackages...)
+	for _, ov := range o.CustomPackages {
+		i := indexByName(len(custom), func(i int) string { return custom[i].Name }, ov.Name)
+		if i < 0 {
+			missing("custom_package", ov.Name)
+			continue
+		}
+		custom[i] = mergeCustomPackage(custom[i], ov)
+	}
+	gh := append([]GithubReleasePackage(nil), cfg.GithubReleasePackages...)
+	for _, ov := range o.GithubReleasePackages {
+		i := indexByName(len(gh), func(i int) string { return gh[i].Name }, ov.Name)
+		if i < 0 {
+			missing("github_release_package", ov.Name)
+			continue
+		}
+		gh[i] = mergeGithubReleasePackage(gh[i], ov)
+	}
+	cfg.Packages, cfg.CustomPackages, cfg.GithubReleasePackages = pkgs, custom, gh
+	return cfg, firstErr
+}
+
+func indexByName(n int, name func(int) string, want string) int {
+	for i := 0; i < n; i++ {
+		if name(i) == want {
+			return i
+		}
+	}
+	return -1
+}
+
+// packageNames returns the names of the packages of every kind in cfg.
+func packageNames(cfg Config) []string {
+	var out []string
+	for _, p := range cfg.Packages {
+		out = append(out, p.Name)
+	}
+	for _, p := range cfg.CustomPackages {
+		out = append(out, p.Name)
+	}
+	for _, p := range cfg.GithubReleasePackages {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
+// inactiveNames returns the names in defined that are not in seen, the
+// packages defined so far that do not apply to this host.
+func inactiveNames(defined map[string]bool, seen map[string]string) map[string]bool {
+	out := map[string]bool{}
+	for n := range defined {
+		if _, ok := seen[n]; !ok {
+			out[n] = true
+		}
+	}
+	return out
+}
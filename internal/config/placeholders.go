@@ -5,6 +5,7 @@ import "fmt"
 const (
 	placeholderPackage     = "{package}"
 	placeholderPackageList = "{package_list}"
+	placeholderVersion     = "{version}"
 )
 
 func ValidatePlaceholders(cfg Config) error {
@@ -30,6 +31,9 @@ func ValidatePlaceholders(cfg Config) error {
 		if err := validateCommandPlaceholders("source", s.Name, "get_latest_version", s.GetLatestVersion); err != nil {
 			return err
 		}
+		if err := validateCommandPlaceholders("source", s.Name, "list_installed", s.ListInstalled); err != nil {
+			return err
+		}
 	}
 
 	for _, cp := range cfg.CustomPackages {
@@ -69,19 +73,18 @@ func validateCommandPlaceholders(kind, name, field string, cmd Command) error {
 	if cmd.Command == "" {
 		return nil
 	}
-	hasPkg := contains(cmd.Command, placeholderPackage)
-	hasList := contains(cmd.Command, placeholderPackageList)
+	hasPkg := HasRef(cmd.Command, placeholderPackage)
+	hasList := HasRef(cmd.Command, placeholderPackageList)
 	if hasPkg && hasList {
 		return fmt.Errorf("invalid placeholders: %s %q %s command contains both %s and %s", kind, name, field, placeholderPackage, placeholderPackageList)
 	}
-	return nil
-}
-
-func contains(s, sub string) bool {
-	for i := 0; i+len(sub) <= len(s); i++ {
-		if s[i:i+len(sub)] == sub {
-			return true
+	if HasRef(cmd.Command, placeholderVersion) {
+		if kind != "source" || (field != "install" && field != "update") {
+			return fmt.Errorf("invalid placeholders: %s %q %s command uses %s, which is only available in source install and update commands", kind, name, field, placeholderVersion)
+		}
+		if !hasPkg {
+			return fmt.Errorf("invalid placeholders: %s %q %s command uses %s without %s", kind, name, field, placeholderVersion, placeholderPackage)
 		}
 	}
-	return false
+	return nil
 }
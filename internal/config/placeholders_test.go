@@ -30,3 +30,28 @@ func TestValidatePlaceholders_Source_OnlyPackage_OK(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestValidatePlaceholders_Version(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+		ok   bool
+	}{
+		{"source install with package", Config{Sources: []Source{{Name: "apt", Install: Command{Command: "apt install {package}={version}"}}}}, true},
+		{"source update with package", Config{Sources: []Source{{Name: "apt", Update: Command{Command: "apt install {package}={version}"}}}}, true},
+		{"with package list", Config{Sources: []Source{{Name: "apt", Install: Command{Command: "apt install {package_list}={version}"}}}}, false},
+		{"source remove", Config{Sources: []Source{{Name: "apt", Remove: Command{Command: "apt remove {package}={version}"}}}}, false},
+		{"custom package", Config{CustomPackages: []CustomPackage{{Name: "t", Install: Command{Command: "get {version}"}}}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePlaceholders(tc.cfg)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.ok && (err == nil || !strings.Contains(err.Error(), "{version}")) {
+				t.Fatalf("expected {version} error, got %v", err)
+			}
+		})
+	}
+}
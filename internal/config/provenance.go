@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultsFile names the embedded default sources in provenance.
+const DefaultsFile = "defaults"
+
+// Origin is where a configuration value was defined.
+type Origin struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// String returns file:line, or the file alone when the line is not known, as
+// for TOML files.
+func (o Origin) String() string {
+	if o.Line == 0 {
+		return o.File
+	}
+	return o.File + ":" + strconv.Itoa(o.Line)
+}
+
+// Provenance maps the path of an entry or field to the place that defined it.
+// Entries are keyed by section and name, such as "packages.git", and fields
+// by the entry path and the field, such as "sources.apt.install". When later
+// files override a source field, or overrides patch a package field, the last
+// definition is recorded.
+type Provenance map[string]Origin
+
+// Lookup returns the origin of the entry called name in any section.
+func (p Provenance) Lookup(name string) (Origin, bool) {
+	for _, sec := range []string{"packages", "custom_packages", "github_release_packages", "sources"} {
+		if o, ok := p[sec+"."+name]; ok {
+			return o, true
+		}
+	}
+	return Origin{}, false
+}
+
+// LoadProvenance records where every entry and field of the configuration
+// loaded from defaultsYAML and files was defined. Entries whose when
+// conditions exclude this host are skipped, like the loader does.
+func LoadProvenance(defaultsYAML []byte, files []string) (Provenance, error) {
+	p := Provenance{}
+	extends := map[string]string{}
+	h := host()
+	add := func(file string, b []byte) error {
+		doc, err := parseDocument(file, b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for _, e := range collectEntries(file, doc, true, h) {
+			if !e.active {
+				continue
+			}
+			sec := sectionKey(e.kind)
+			path := sec + "." + e.name
+			if _, ok := p[path]; !e.override && (!ok || e.kind != "source") {
+				p[path] = Origin{File: file, Line: e.node.Line}
+			}
+			for i := 0; i+1 < len(e.node.Content); i += 2 {
+				k := e.node.Content[i]
+				if k.Value == "name" || e.override && k.Value == "when" {
+					continue
+				}
+				p[path+"."+k.Value] = Origin{File: file, Line: k.Line}
+				if e.kind == "source" && k.Value == "extends" {
+					extends[e.name] = e.node.Content[i+1].Value
+				}
+			}
+		}
+		return nil
+	}
+	if len(defaultsYAML) > 0 {
+		if err := add(DefaultsFile, defaultsYAML); err != nil {
+			return nil, err
+		}
+	}
+	order, err := expandIncludes(files)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range order {
+		b, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(f.name(), b); err != nil {
+			return nil, err
+		}
+	}
+	inheritProvenance(p, extends)
+	return p, nil
+}
+
+// inheritProvenance gives sources that extend another the origins of the
+// fields they inherit.
+func inheritProvenance(p Provenance, extends map[string]string) {
+	done := map[string]bool{}
+	var visit func(name string, depth int)
+	visit = func(name string, depth int) {
+		base, ok := extends[name]
+		if !ok || done[name] || depth > len(extends) {
+			return
+		}
+		done[name] = true
+		visit(base, depth+1)
+		prefix := "sources." + base + "."
+		for k, o := range p {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			field := "sources." + name + "." + strings.TrimPrefix(k, prefix)
+			if _, ok := p[field]; !ok {
+				p[field] = o
+			}
+		}
+	}
+	for name := range extends {
+		visit(name, 0)
+	}
+}
+
+func sectionKey(kind string) string {
+	for _, sec := range lintSections {
+		if sec.kind == kind {
+			return sec.key
+		}
+	}
+	return kind
+}
+
+// EncodeYAML renders cfg as YAML without empty fields. With p, every entry
+// and field is followed by a comment naming where it was defined; entries
+// missing from p are added at run time, such as the gopak-cli self-update
+// package.
+func EncodeYAML(cfg Config, p Provenance) ([]byte, error) {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return nil, err
+	}
+	pruneEmpty(&root)
+	if p != nil {
+		annotate(&root, p)
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pruneEmpty drops mapping entries whose value is empty, false or null, and
+// collapses commands that do not require root to their command string.
+func pruneEmpty(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return n.Tag == "!!null" || n.Value == "" && n.Tag == "!!str" || n.Tag == "!!bool" && n.Value == "false"
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			pruneEmpty(c)
+		}
+		return len(n.Content) == 0
+	case yaml.MappingNode:
+		var kept []*yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if pruneEmpty(n.Content[i+1]) {
+				continue
+			}
+			kept = append(kept, n.Content[i], n.Content[i+1])
+		}
+		n.Content = kept
+		if len(kept) == 2 && kept[0].Value == "command" && kept[1].Kind == yaml.ScalarNode {
+			*n = *kept[1]
+		}
+		return len(n.Content) == 0 && n.Kind == yaml.MappingNode
+	}
+	return false
+}
+
+func annotate(root *yaml.Node, p Provenance) {
+	if root.Kind != yaml.MappingNode {
+		return
+	}
+	for _, sec := range lintSections {
+		_, seq := mappingValue(root, sec.key)
+		if seq == nil {
+			continue
+		}
+		for _, item := range seq.Content {
+			_, name := mappingValue(item, "name")
+			if name == nil {
+				continue
+			}
+			path := sec.key + "." + name.Value
+			o, ok := p[path]
+			if !ok {
+				name.LineComment = "# built in"
+				continue
+			}
+			name.LineComment = "# " + o.String()
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				k, v := item.Content[i], item.Content[i+1]
+				fo, ok := p[path+"."+k.Value]
+				if k.Value == "name" || !ok {
+					continue
+				}
+				if v.Kind == yaml.ScalarNode {
+					v.LineComment = "# " + fo.String()
+				} else {
+					k.LineComment = "# " + fo.String()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/assets"
+)
+
+func TestLoadProvenance(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.yaml")
+	writeFile(t, f, `sources:
+  - name: apt
+    type: package_manager
+    install:
+      command: my-apt {package}
+      require_root: true
+packages:
+  - name: git
+    source: apt
+`)
+	p, err := LoadProvenance(assets.DefaultSources, []string{f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := p["sources.apt"]; o.File != DefaultsFile {
+		t.Fatalf("apt entry should come from the defaults: %v", o)
+	}
+	if o := p["sources.apt.install"]; o.File != f || o.Line != 4 {
+		t.Fatalf("apt install = %v, want %s:4", o, f)
+	}
+	if o := p["sources.apt.remove"]; o.File != DefaultsFile {
+		t.Fatalf("apt remove = %v", o)
+	}
+	if o, ok := p.Lookup("git"); !ok || o.File != f || o.Line != 8 {
+		t.Fatalf("git = %v, %v", o, ok)
+	}
+}
+
+func TestEncodeYAML_Provenance(t *testing.T) {
+	cfg := Config{
+		Packages: []Package{{Name: "git", Source: "apt"}},
+		CustomPackages: []CustomPackage{{
+			Name:    "tool",
+			Install: Command{Command: "make install", RequireRoot: true},
+			Remove:  Command{Command: "rm tool"},
+		}},
+	}
+	p := Provenance{
+		"packages.git":        {File: "a.yaml", Line: 2},
+		"packages.git.source": {File: "a.yaml", Line: 3},
+	}
+	b, err := EncodeYAML(cfg, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, want := range []string{
+		"- name: git # a.yaml:2\n",
+		"source: apt # a.yaml:3\n",
+		"- name: tool # built in\n",
+		"install:\n      command: make install\n      require_root: true\n",
+		"remove: rm tool\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "get_installed_version") || strings.Contains(out, "depends_on") {
+		t.Errorf("empty fields should be omitted:\n%s", out)
+	}
+}
+
+func TestLoadProvenance_Extends(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.yaml")
+	writeFile(t, f, `sources:
+  - name: apt-backports
+    extends: apt
+    install:
+      command: apt install -t backports {package_list}
+`)
+	p, err := LoadProvenance(assets.DefaultSources, []string{f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := p["sources.apt-backports.install"]; o.File != f || o.Line != 4 {
+		t.Fatalf("install = %v", o)
+	}
+	if o := p["sources.apt-backports.remove"]; o != p["sources.apt.remove"] || o.File != DefaultsFile {
+		t.Fatalf("remove = %v, want the origin of apt remove", o)
+	}
+}
+
+func TestLoadProvenance_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	writeFile(t, a, `packages:
+  - name: git
+    source: apt
+    depends_on: [curl]
+`)
+	writeFile(t, b, `overrides:
+  packages:
+    - name: git
+      depends_on: []
+`)
+	p, err := LoadProvenance(nil, []string{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := p["packages.git"]; o.File != a || o.Line != 2 {
+		t.Fatalf("git = %v, want %s:2", o, a)
+	}
+	if o := p["packages.git.depends_on"]; o.File != b || o.Line != 4 {
+		t.Fatalf("depends_on = %v, want %s:4", o, b)
+	}
+	if o := p["packages.git.source"]; o.File != a {
+		t.Fatalf("source = %v", o)
+	}
+}
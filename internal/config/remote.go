@@ -0,0 +1,145 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxIncludeSize bounds the size of a remote include.
+const maxIncludeSize = 10 << 20
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// warn reports a problem that does not stop loading. Logging is not set up
+// while the configuration loads, so it goes to stderr.
+var warn = func(msg string) { fmt.Fprintln(os.Stderr, "warning: "+msg) }
+
+// CacheDir is where gopak caches data: $XDG_CACHE_HOME/gopak, or
+// ~/.cache/gopak.
+func CacheDir() string {
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "gopak")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".cache", "gopak")
+	}
+	return filepath.Join(home, ".cache", "gopak")
+}
+
+// includeCachePath is the cached copy of the remote include at u. It keeps
+// the extension of the URL, which selects the format.
+func includeCachePath(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+	ext := formatExt(u.String())
+	if !slices.Contains(configExts, ext) {
+		ext = ".yaml"
+	}
+	return filepath.Join(CacheDir(), "includes", hex.EncodeToString(sum[:8])+ext)
+}
+
+// fetchInclude returns the cached copy of a remote include, downloading it
+// first unless the cache already holds the pinned checksum or is younger than
+// the refresh interval. A download must match the pinned checksum. When the
+// download fails, an unpinned include falls back to the cached copy.
+func fetchInclude(inc Include) (string, error) {
+	u, err := url.Parse(inc.URL)
+	if err != nil {
+		return "", fmt.Errorf("include %s: %w", inc.URL, err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("include %s: only https URLs are supported", inc.URL)
+	}
+	if inc.SHA256 == "" && inc.Refresh == "" {
+		return "", fmt.Errorf("include %s: set sha256 or refresh", inc.URL)
+	}
+	var refresh time.Duration
+	if inc.Refresh != "" {
+		if refresh, err = time.ParseDuration(inc.Refresh); err != nil || refresh <= 0 {
+			return "", fmt.Errorf("include %s: invalid refresh %q", inc.URL, inc.Refresh)
+		}
+	}
+	p := includeCachePath(u)
+	st, statErr := os.Stat(p)
+	cached := statErr == nil
+	if cached {
+		if inc.SHA256 != "" {
+			if b, err := os.ReadFile(p); err == nil && strings.EqualFold(sha256Hex(b), inc.SHA256) {
+				return p, nil
+			}
+		} else if time.Since(st.ModTime()) < refresh {
+			return p, nil
+		}
+	}
+	b, err := download(inc.URL)
+	if err != nil {
+		if cached && inc.SHA256 == "" {
+			warn(fmt.Sprintf("include %s: %v; using the copy cached on %s", inc.URL, err, st.ModTime().Format(time.DateTime)))
+			return p, nil
+		}
+		return "", fmt.Errorf("include %s: %w", inc.URL, err)
+	}
+	if inc.SHA256 != "" {
+		if got := sha256Hex(b); !strings.EqualFold(got, inc.SHA256) {
+			return "", fmt.Errorf("include %s: sha256 mismatch: got %s, want %s", inc.URL, got, inc.SHA256)
+		}
+	}
+	if err := writeCache(p, b); err != nil {
+		return "", fmt.Errorf("include %s: %w", inc.URL, err)
+	}
+	return p, nil
+}
+
+func download(rawURL string) ([]byte, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET: %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxIncludeSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxIncludeSize {
+		return nil, errors.New("file is larger than 10 MiB")
+	}
+	return b, nil
+}
+
+// writeCache replaces the cached file at p atomically.
+func writeCache(p string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".include-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
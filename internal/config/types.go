@@ -74,6 +74,7 @@ func (e *Executable) UnmarshalJSON(data []byte) error {
 type Source struct {
 	Type                string  `mapstructure:"type" yaml:"type" json:"type"`
 	Name                string  `mapstructure:"name" yaml:"name" json:"name"`
+	Extends             string  `mapstructure:"extends" yaml:"extends" json:"extends,omitempty"`
 	Install             Command `mapstructure:"install" yaml:"install" json:"install"`
 	Remove              Command `mapstructure:"remove" yaml:"remove" json:"remove"`
 	Update              Command `mapstructure:"update" yaml:"update" json:"update"`
@@ -81,28 +82,61 @@ type Source struct {
 	PreUpdate           Command `mapstructure:"pre_update" yaml:"pre_update" json:"pre_update"`
 	GetInstalledVersion Command `mapstructure:"get_installed_version" yaml:"get_installed_version" json:"get_installed_version"`
 	GetLatestVersion    Command `mapstructure:"get_latest_version" yaml:"get_latest_version" json:"get_latest_version"`
+	ListInstalled       Command `mapstructure:"list_installed" yaml:"list_installed" json:"list_installed"`
+	VersionScheme       string  `mapstructure:"version_scheme" yaml:"version_scheme" json:"version_scheme,omitempty"`
+	VersionRegex        string  `mapstructure:"version_regex" yaml:"version_regex" json:"version_regex,omitempty"`
 }
 
 type Package struct {
-	Name       string     `mapstructure:"name" yaml:"name" json:"name"`
-	Source     string     `mapstructure:"source" yaml:"source" json:"source"`
-	DependsOn  []string   `mapstructure:"depends_on" yaml:"depends_on" json:"depends_on,omitempty"`
-	Executable Executable `mapstructure:"executable" yaml:"executable" json:"executable,omitempty"`
+	Name          string     `mapstructure:"name" yaml:"name" json:"name"`
+	Source        string     `mapstructure:"source" yaml:"source" json:"source,omitempty"`
+	Version       string     `mapstructure:"version" yaml:"version" json:"version,omitempty"`
+	VersionScheme string     `mapstructure:"version_scheme" yaml:"version_scheme" json:"version_scheme,omitempty"`
+	VersionRegex  string     `mapstructure:"version_regex" yaml:"version_regex" json:"version_regex,omitempty"`
+	DependsOn     []string   `mapstructure:"depends_on" yaml:"depends_on" json:"depends_on,omitempty"`
+	Tags          []string   `mapstructure:"tags" yaml:"tags" json:"tags,omitempty"`
+	Executable    Executable `mapstructure:"executable" yaml:"executable" json:"executable,omitempty"`
+	When          *When      `mapstructure:"when" yaml:"when" json:"when,omitempty"`
+	// Alternatives replace Source: the first alternative available on this
+	// host installs the package.
+	Alternatives []Alternative `mapstructure:"alternatives" yaml:"alternatives" json:"alternatives,omitempty"`
+}
+
+// Alternative is one way to install a package: from a source, under the name
+// the source knows it by, or from the GitHub releases of a repository.
+type Alternative struct {
+	Source string `mapstructure:"source" yaml:"source" json:"source,omitempty"`
+	// Name is the package name within Source; empty means the name of the
+	// package.
+	Name                string  `mapstructure:"name" yaml:"name" json:"name,omitempty"`
+	Github              string  `mapstructure:"github" yaml:"github" json:"github,omitempty"`
+	AssetPattern        string  `mapstructure:"asset_pattern" yaml:"asset_pattern" json:"asset_pattern,omitempty"`
+	GetInstalledVersion Command `mapstructure:"get_installed_version" yaml:"get_installed_version" json:"get_installed_version"`
+	PostInstall         Command `mapstructure:"post_install" yaml:"post_install" json:"post_install"`
+	Remove              Command `mapstructure:"remove" yaml:"remove" json:"remove"`
 }
 
 type CustomPackage struct {
 	Name                string     `mapstructure:"name" yaml:"name" json:"name"`
+	Version             string     `mapstructure:"version" yaml:"version" json:"version,omitempty"`
+	VersionScheme       string     `mapstructure:"version_scheme" yaml:"version_scheme" json:"version_scheme,omitempty"`
+	VersionRegex        string     `mapstructure:"version_regex" yaml:"version_regex" json:"version_regex,omitempty"`
 	Executable          Executable `mapstructure:"executable" yaml:"executable" json:"executable,omitempty"`
 	DependsOn           []string   `mapstructure:"depends_on" yaml:"depends_on" json:"depends_on,omitempty"`
+	Tags                []string   `mapstructure:"tags" yaml:"tags" json:"tags,omitempty"`
 	GetInstalledVersion Command    `mapstructure:"get_installed_version" yaml:"get_installed_version" json:"get_installed_version"`
 	GetLatestVersion    Command    `mapstructure:"get_latest_version" yaml:"get_latest_version" json:"get_latest_version"`
 	Install             Command    `mapstructure:"install" yaml:"install" json:"install"`
 	Update              Command    `mapstructure:"update" yaml:"update" json:"update"`
 	Remove              Command    `mapstructure:"remove" yaml:"remove" json:"remove"`
+	When                *When      `mapstructure:"when" yaml:"when" json:"when,omitempty"`
 }
 
 type GithubReleasePackage struct {
 	Name                string     `mapstructure:"name" yaml:"name" json:"name"`
+	Version             string     `mapstructure:"version" yaml:"version" json:"version,omitempty"`
+	VersionScheme       string     `mapstructure:"version_scheme" yaml:"version_scheme" json:"version_scheme,omitempty"`
+	VersionRegex        string     `mapstructure:"version_regex" yaml:"version_regex" json:"version_regex,omitempty"`
 	Executable          Executable `mapstructure:"executable" yaml:"executable" json:"executable,omitempty"`
 	Repo                string     `mapstructure:"repo" yaml:"repo" json:"repo"`
 	AssetPattern        string     `mapstructure:"asset_pattern" yaml:"asset_pattern" json:"asset_pattern"`
@@ -110,14 +144,38 @@ type GithubReleasePackage struct {
 	PostInstall         Command    `mapstructure:"post_install" yaml:"post_install" json:"post_install"`
 	Remove              Command    `mapstructure:"remove" yaml:"remove" json:"remove"`
 	DependsOn           []string   `mapstructure:"depends_on" yaml:"depends_on" json:"depends_on,omitempty"`
+	Tags                []string   `mapstructure:"tags" yaml:"tags" json:"tags,omitempty"`
+	When                *When      `mapstructure:"when" yaml:"when" json:"when,omitempty"`
 }
 
 type Config struct {
+	Include               []Include              `mapstructure:"include" yaml:"include" json:"include,omitempty"`
 	Sources               []Source               `mapstructure:"sources" yaml:"sources" json:"sources,omitempty"`
 	Packages              []Package              `mapstructure:"packages" yaml:"packages" json:"packages,omitempty"`
 	CustomPackages        []CustomPackage        `mapstructure:"custom_packages" yaml:"custom_packages" json:"custom_packages,omitempty"`
 	GithubReleasePackages []GithubReleasePackage `mapstructure:"github_release_packages" yaml:"github_release_packages" json:"github_release_packages,omitempty"`
 	ExecCacheTTL          string                 `mapstructure:"exec_cache_ttl" yaml:"exec_cache_ttl" json:"exec_cache_ttl,omitempty"`
+	Profiles              map[string][]string    `mapstructure:"profiles" yaml:"profiles" json:"profiles,omitempty"`
+	Vars                  map[string]string      `mapstructure:"vars" yaml:"vars" json:"vars,omitempty"`
+	Overrides             *Overrides             `mapstructure:"overrides" yaml:"overrides" json:"overrides,omitempty"`
+	// SecretFile, SecretIdentity and SecretCommand resolve ${secret:name}
+	// references; see package secret.
+	SecretFile     string   `mapstructure:"secret_file" yaml:"secret_file" json:"secret_file,omitempty"`
+	SecretIdentity string   `mapstructure:"secret_identity" yaml:"secret_identity" json:"secret_identity,omitempty"`
+	SecretCommand  *Command `mapstructure:"secret_command" yaml:"secret_command" json:"secret_command,omitempty"`
+	// Dir is the directory the configuration was loaded from; it is the value
+	// of {config_dir}.
+	Dir string `mapstructure:"-" yaml:"-" json:"-"`
+}
+
+// Overrides patches packages defined earlier, in a previous or included file
+// or in the defaults. Every field an override sets replaces the field of the
+// package with the same name; its when condition restricts the override
+// itself.
+type Overrides struct {
+	Packages              []Package              `mapstructure:"packages" yaml:"packages" json:"packages,omitempty"`
+	CustomPackages        []CustomPackage        `mapstructure:"custom_packages" yaml:"custom_packages" json:"custom_packages,omitempty"`
+	GithubReleasePackages []GithubReleasePackage `mapstructure:"github_release_packages" yaml:"github_release_packages" json:"github_release_packages,omitempty"`
 }
 
 func (c Config) ParsedExecCacheTTL() time.Duration {
@@ -131,33 +189,89 @@ func (c Config) ParsedExecCacheTTL() time.Duration {
 	return d
 }
 
+// Command is a shell command run for a package or source. It may be written
+// as a plain string or as a mapping with options.
 type Command struct {
 	Command     string `mapstructure:"command" yaml:"command" json:"command"`
 	RequireRoot bool   `mapstructure:"require_root" yaml:"require_root" json:"require_root"`
+	// Env is added to the environment of the command, also under sudo.
+	Env map[string]string `mapstructure:"env" yaml:"env,omitempty" json:"env,omitempty"`
+	// Cwd is the working directory; empty keeps the current one.
+	Cwd string `mapstructure:"cwd" yaml:"cwd,omitempty" json:"cwd,omitempty"`
+	// Shell runs the command: sh, bash, zsh or pwsh. Empty means bash, or
+	// cmd on Windows.
+	Shell string `mapstructure:"shell" yaml:"shell,omitempty" json:"shell,omitempty"`
+	// Timeout is a duration such as 30s or 10m after which the command is
+	// stopped; empty means no limit.
+	Timeout string `mapstructure:"timeout" yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// Shells lists the values accepted in Command.Shell.
+var Shells = []string{"sh", "bash", "zsh", "pwsh"}
+
+// ParsedTimeout returns the timeout of c, or zero when it has none.
+func (c Command) ParsedTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", c.Timeout)
+	}
+	return d, nil
 }
 
 func (c *Command) UnmarshalYAML(value *yaml.Node) error {
 	switch value.Kind {
 	case yaml.ScalarNode:
-		c.Command = value.Value
-		c.RequireRoot = false
+		*c = Command{Command: value.Value}
 		return nil
 	case yaml.MappingNode:
-		var aux struct {
-			Command     string `yaml:"command"`
-			RequireRoot *bool  `yaml:"require_root"`
-		}
+		type plain Command
+		var aux plain
 		if err := value.Decode(&aux); err != nil {
 			return err
 		}
-		c.Command = aux.Command
-		if aux.RequireRoot != nil {
-			c.RequireRoot = *aux.RequireRoot
-		} else {
-			c.RequireRoot = false
-		}
+		*c = Command(aux)
 		return nil
 	default:
 		return fmt.Errorf("invalid command node kind: %d", value.Kind)
 	}
 }
+
+// Include is an entry of include: a local file, glob or directory, or a file
+// fetched over HTTPS that is pinned by its SHA-256 checksum or refreshed
+// after an interval.
+type Include struct {
+	Path    string `mapstructure:"-" yaml:"-" json:"-"`
+	URL     string `mapstructure:"url" yaml:"url" json:"url,omitempty"`
+	SHA256  string `mapstructure:"sha256" yaml:"sha256" json:"sha256,omitempty"`
+	Refresh string `mapstructure:"refresh" yaml:"refresh" json:"refresh,omitempty"`
+}
+
+func (i *Include) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*i = Include{Path: value.Value}
+		return nil
+	case yaml.MappingNode:
+		type plain Include
+		var aux plain
+		if err := value.Decode(&aux); err != nil {
+			return err
+		}
+		*i = Include(aux)
+		return nil
+	default:
+		return fmt.Errorf("include must be a path or a mapping with url")
+	}
+}
+
+// MarshalJSON writes a local include as its path.
+func (i Include) MarshalJSON() ([]byte, error) {
+	if i.URL == "" {
+		return json.Marshal(i.Path)
+	}
+	type plain Include
+	return json.Marshal(plain(i))
+}
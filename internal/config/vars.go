@@ -0,0 +1,312 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// varRe matches {name} references.
+var varRe = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// envNameRe matches the names accepted in Command.Env.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidEnvName reports whether name can be used as an environment variable
+// of a command.
+func ValidEnvName(name string) bool { return envNameRe.MatchString(name) }
+
+// secretRe matches ${secret:name} references, which are resolved when a
+// command runs rather than from vars.
+var secretRe = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_.-]+)\}`)
+
+// SecretRefs returns the names of the secrets referenced in s, in order.
+func SecretRefs(s string) []string {
+	var out []string
+	for _, m := range secretRe.FindAllStringSubmatch(s, -1) {
+		out = append(out, m[1])
+	}
+	return out
+}
+
+// ReplaceSecretRefs replaces every ${secret:name} reference in s with
+// repl(name).
+func ReplaceSecretRefs(s string, repl func(name string) string) string {
+	return secretRe.ReplaceAllStringFunc(s, func(m string) string {
+		return repl(secretRe.FindStringSubmatch(m)[1])
+	})
+}
+
+// VarName is the built-in variable holding the name of the package or source
+// a command belongs to. It differs per entry and cannot be redefined.
+const VarName = "name"
+
+// placeholderNames are expanded per package or query at run time rather than
+// from vars.
+var placeholderNames = map[string]bool{"package": true, "package_list": true, "version": true, "query": true}
+
+// escapedRe matches {{name}}, the escape for a literal {name}.
+var escapedRe = regexp.MustCompile(`\{\{([A-Za-z_][A-Za-z0-9_]*)\}\}`)
+
+// varMatches returns the index pairs of the references in s. A reference
+// preceded by $ is shell parameter expansion such as ${HOME} and is skipped,
+// and so is one written with doubled braces, {{name}}.
+func varMatches(s string) [][]int {
+	var out [][]int
+	for _, m := range varRe.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] > 0 && s[m[0]-1] == '$' {
+			continue
+		}
+		if m[0] > 0 && s[m[0]-1] == '{' && m[1] < len(s) && s[m[1]] == '}' {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// HasRef reports whether s references ref, a variable or placeholder written
+// as {name}, other than through the {{name}} escape.
+func HasRef(s, ref string) bool {
+	for _, m := range varMatches(s) {
+		if s[m[0]:m[1]] == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceRef replaces the references to ref in s with value, leaving
+// escaped ones alone.
+func ReplaceRef(s, ref, value string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range varMatches(s) {
+		if s[m[0]:m[1]] != ref {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(value)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// UnescapeRefs turns every {{name}} in s into {name}. Commands are unescaped
+// just before they run, once variables and placeholders are expanded.
+func UnescapeRefs(s string) string {
+	return escapedRe.ReplaceAllString(s, "{$1}")
+}
+
+// VarRefs returns the names of the variables referenced in s, in order.
+func VarRefs(s string) []string {
+	var out []string
+	for _, m := range varMatches(s) {
+		out = append(out, s[m[2]:m[3]])
+	}
+	return out
+}
+
+// ExpandVarRefs replaces the references in s to variables present in vars.
+// Other references are kept as written.
+func ExpandVarRefs(s string, vars map[string]string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range varMatches(s) {
+		v, ok := vars[s[m[2]:m[3]]]
+		if !ok {
+			continue
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(v)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// BuiltinVars returns the built-in variables other than {name} for a
+// configuration loaded from dir.
+func BuiltinVars(dir string) map[string]string {
+	home := homeDir()
+	return map[string]string{
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"home":       home,
+		"bin_dir":    filepath.Join(home, ".local", "bin"),
+		"config_dir": dir,
+	}
+}
+
+// homeDir is the home of the invoking user, also when running under sudo.
+func homeDir() string {
+	if su := os.Getenv("SUDO_USER"); su != "" {
+		if u, err := user.Lookup(su); err == nil && u.HomeDir != "" {
+			return u.HomeDir
+		}
+	}
+	h, _ := os.UserHomeDir()
+	return h
+}
+
+// ResolveVars combines the built-in variables with cfg.Vars, which override
+// them, and expands references between variables. {name} is kept, as it is
+// only known per entry.
+func ResolveVars(cfg Config) (map[string]string, error) {
+	out := BuiltinVars(cfg.Dir)
+	names := make([]string, 0, len(cfg.Vars))
+	for k := range cfg.Vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if k == VarName || placeholderNames[k] {
+			return nil, fmt.Errorf("invalid vars: %q is reserved", k)
+		}
+	}
+	resolving := map[string]bool{}
+	done := map[string]bool{}
+	var resolve func(k string) error
+	resolve = func(k string) error {
+		if done[k] {
+			return nil
+		}
+		if resolving[k] {
+			return fmt.Errorf("invalid vars: %q refers to itself", k)
+		}
+		resolving[k] = true
+		v := cfg.Vars[k]
+		for _, ref := range VarRefs(v) {
+			if _, ok := cfg.Vars[ref]; ok {
+				if err := resolve(ref); err != nil {
+					return err
+				}
+			} else if _, ok := out[ref]; !ok && ref != VarName {
+				return fmt.Errorf("invalid vars: %q uses unknown variable {%s}", k, ref)
+			}
+		}
+		out[k] = ExpandVarRefs(v, out)
+		done[k] = true
+		return nil
+	}
+	for _, k := range names {
+		if err := resolve(k); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// unknownVars returns the references in s that are neither variables,
+// {name}, nor run-time placeholders.
+func unknownVars(s string, vars map[string]string) []string {
+	var out []string
+	for _, ref := range VarRefs(s) {
+		if _, ok := vars[ref]; ok || ref == VarName || placeholderNames[ref] {
+			continue
+		}
+		out = append(out, ref)
+	}
+	return out
+}
+
+// ValidateVars checks that vars resolve, that commands, their cwd and env
+// values, and asset patterns only reference known variables, and that env
+// names are valid variable names.
+func ValidateVars(cfg Config) error {
+	vars, err := ResolveVars(cfg)
+	if err != nil {
+		return err
+	}
+	check := func(kind, name, field, s string) error {
+		if u := unknownVars(s, vars); len(u) > 0 {
+			return fmt.Errorf("unknown variable: %s %q %s uses {%s}; write {{%s}} for literal braces", kind, name, field, strings.Join(u, "}, {"), u[0])
+		}
+		return nil
+	}
+	checkCommand := func(kind, name, field string, c Command) error {
+		if err := check(kind, name, field, c.Command); err != nil {
+			return err
+		}
+		if err := check(kind, name, field+" cwd", c.Cwd); err != nil {
+			return err
+		}
+		for k, v := range c.Env {
+			if !ValidEnvName(k) {
+				return fmt.Errorf("invalid env: %s %q %s sets %q, which is not a valid variable name", kind, name, field, k)
+			}
+			if err := check(kind, name, field+" env "+k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, s := range cfg.Sources {
+		for field, c := range sourceCommands(s) {
+			if err := checkCommand("source", s.Name, field, c); err != nil {
+				return err
+			}
+		}
+	}
+	for _, cp := range cfg.CustomPackages {
+		for field, c := range customCommands(cp) {
+			if err := checkCommand("custom_package", cp.Name, field, c); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range cfg.Packages {
+		for _, a := range p.Alternatives {
+			if a.Github == "" {
+				continue
+			}
+			gp := GithubReleasePackage{GetInstalledVersion: a.GetInstalledVersion, PostInstall: a.PostInstall, Remove: a.Remove}
+			for field, c := range githubCommands(gp) {
+				if err := checkCommand("package", p.Name, "alternative "+a.Github+" "+field, c); err != nil {
+					return err
+				}
+			}
+			if err := check("package", p.Name, "alternative "+a.Github+" asset_pattern", a.AssetPattern); err != nil {
+				return err
+			}
+		}
+	}
+	for _, gp := range cfg.GithubReleasePackages {
+		for field, c := range githubCommands(gp) {
+			if err := checkCommand("github_release_package", gp.Name, field, c); err != nil {
+				return err
+			}
+		}
+		if err := check("github_release_package", gp.Name, "asset_pattern", gp.AssetPattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sourceCommands(s Source) map[string]Command {
+	return map[string]Command{
+		"install": s.Install, "remove": s.Remove, "update": s.Update, "search": s.Search,
+		"pre_update": s.PreUpdate, "get_installed_version": s.GetInstalledVersion,
+		"get_latest_version": s.GetLatestVersion, "list_installed": s.ListInstalled,
+	}
+}
+
+func customCommands(cp CustomPackage) map[string]Command {
+	return map[string]Command{
+		"get_installed_version": cp.GetInstalledVersion, "get_latest_version": cp.GetLatestVersion,
+		"install": cp.Install, "update": cp.Update, "remove": cp.Remove,
+	}
+}
+
+func githubCommands(gp GithubReleasePackage) map[string]Command {
+	return map[string]Command{
+		"get_installed_version": gp.GetInstalledVersion, "post_install": gp.PostInstall, "remove": gp.Remove,
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVarRefs(t *testing.T) {
+	got := VarRefs(`cp x {bin_dir}/{name}{suffix} && echo ${HOME} {package} '{print $2}'`)
+	want := []string{"bin_dir", "name", "suffix", "package"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("VarRefs = %v, want %v", got, want)
+	}
+}
+
+func TestResolveVars(t *testing.T) {
+	cfg := Config{Dir: "/cfg", Vars: map[string]string{
+		"tools":   "{home}/tools",
+		"bin_dir": "{tools}/bin",
+	}}
+	vars, err := ResolveVars(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := BuiltinVars("")["home"]
+	if vars["bin_dir"] != home+"/tools/bin" || vars["config_dir"] != "/cfg" {
+		t.Fatalf("vars = %v", vars)
+	}
+
+	if _, err := ResolveVars(Config{Vars: map[string]string{"a": "{b}", "b": "{a}"}}); err == nil || !strings.Contains(err.Error(), "refers to itself") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	if _, err := ResolveVars(Config{Vars: map[string]string{"package": "x"}}); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Fatalf("expected reserved error, got %v", err)
+	}
+}
+
+func TestValidateVars(t *testing.T) {
+	cfg := Config{
+		Vars: map[string]string{"prefix": "/opt"},
+		CustomPackages: []CustomPackage{{
+			Name:    "tool",
+			Install: Command{Command: "install -m755 tool {prefix}/{name} {bin_dir}/tool-{os}-{arch}"},
+		}},
+		GithubReleasePackages: []GithubReleasePackage{{Name: "gh", AssetPattern: "gh_{os}_{arch}.tar.gz"}},
+	}
+	if err := ValidateVars(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.GithubReleasePackages[0].AssetPattern = "gh_{platform}.tar.gz"
+	err := ValidateVars(cfg)
+	if err == nil || !strings.Contains(err.Error(), `"gh" asset_pattern uses {platform}`) {
+		t.Fatalf("expected unknown variable error, got %v", err)
+	}
+}
+
+func TestValidateVars_EscapedBraces(t *testing.T) {
+	cfg := Config{CustomPackages: []CustomPackage{{
+		Name:                "tool",
+		GetInstalledVersion: Command{Command: "tool --version | awk '{print}'"},
+	}}}
+	err := ValidateVars(cfg)
+	if err == nil || !strings.Contains(err.Error(), "uses {print}; write {{print}} for literal braces") {
+		t.Fatalf("expected unknown variable error with hint, got %v", err)
+	}
+	cfg.CustomPackages[0].GetInstalledVersion.Command = "tool --version | awk '{{print}}'"
+	cfg.CustomPackages[0].GetLatestVersion.Command = "curl -s {{url}} | jq -r '{{name}} | .name'"
+	if err := ValidateVars(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := "jq '{{name}}' {name} {{version}} {version}"
+	if got := ExpandVarRefs(s, map[string]string{"name": "tool"}); got != "jq '{{name}}' tool {{version}} {version}" {
+		t.Fatalf("ExpandVarRefs = %q", got)
+	}
+	if got := ReplaceRef(s, "{version}", "1.0"); got != "jq '{{name}}' {name} {{version}} 1.0" {
+		t.Fatalf("ReplaceRef = %q", got)
+	}
+	if got := UnescapeRefs(s); got != "jq '{name}' {name} {version} {version}" {
+		t.Fatalf("UnescapeRefs = %q", got)
+	}
+	if HasRef("echo {{package}}", "{package}") {
+		t.Fatal("an escaped placeholder is not a reference")
+	}
+}
+
+func TestValidateVars_EnvNames(t *testing.T) {
+	for _, name := range []string{"-i", "A=B", "1X", ""} {
+		cfg := Config{CustomPackages: []CustomPackage{{
+			Name:    "tool",
+			Install: Command{Command: "make install", Env: map[string]string{name: "x"}},
+		}}}
+		err := ValidateVars(cfg)
+		if err == nil || !strings.Contains(err.Error(), "not a valid variable name") {
+			t.Fatalf("%q: expected env name error, got %v", name, err)
+		}
+		if err := ValidateAgainstSchema(cfg); err == nil {
+			t.Fatalf("%q: schema accepted the env name", name)
+		}
+	}
+	cfg := Config{CustomPackages: []CustomPackage{{
+		Name:    "tool",
+		Install: Command{Command: "make install", Env: map[string]string{"GOFLAGS": "-mod=mod", "_x1": ""}},
+	}}}
+	if err := ValidateVars(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
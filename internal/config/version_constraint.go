@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/version"
+)
+
+// ValidateVersionConstraints checks that the version field of every package
+// parses under the version scheme the package is compared with. A package
+// with alternatives is checked against the scheme of each of them.
+func ValidateVersionConstraints(cfg Config) error {
+	schemes := map[string]string{}
+	for _, s := range cfg.Sources {
+		schemes[s.Name] = s.VersionScheme
+	}
+	for _, p := range cfg.Packages {
+		for _, scheme := range packageSchemes(p, schemes) {
+			if err := validateVersionConstraint("package", p.Name, p.Version, scheme); err != nil {
+				return err
+			}
+		}
+	}
+	for _, cp := range cfg.CustomPackages {
+		if err := validateVersionConstraint("custom_package", cp.Name, cp.Version, cp.VersionScheme); err != nil {
+			return err
+		}
+	}
+	for _, gp := range cfg.GithubReleasePackages {
+		if err := validateVersionConstraint("github_release_package", gp.Name, gp.Version, gp.VersionScheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packageSchemes returns the version schemes p may be compared with: its own,
+// or else that of its source or of each of its alternatives.
+func packageSchemes(p Package, sourceSchemes map[string]string) []string {
+	if p.VersionScheme != "" || (p.Source == "" && len(p.Alternatives) == 0) {
+		return []string{p.VersionScheme}
+	}
+	if p.Source != "" {
+		return []string{sourceSchemes[p.Source]}
+	}
+	var out []string
+	for _, a := range p.Alternatives {
+		out = append(out, sourceSchemes[a.Source])
+	}
+	return out
+}
+
+func validateVersionConstraint(kind, name, constraint, scheme string) error {
+	if strings.TrimSpace(constraint) == "" {
+		return nil
+	}
+	sc, err := version.ParseScheme(scheme)
+	if err != nil {
+		sc = version.Numeric
+	}
+	if _, err := version.ParseConstraint(constraint, sc); err != nil {
+		return fmt.Errorf("invalid version: %s %q: %w (version scheme %s)", kind, name, err, sc)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateVersionConstraints(t *testing.T) {
+	semver := []Source{{Name: "cargo", VersionScheme: "semver"}, {Name: "apt"}}
+	cases := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"range", Config{CustomPackages: []CustomPackage{{Name: "tool", Version: ">=1.2 <2"}}}, ""},
+		{"garbage", Config{GithubReleasePackages: []GithubReleasePackage{{Name: "gh", Version: ">=2.x.y garbage !!"}}}, `invalid version: github_release_package "gh"`},
+		{"source scheme", Config{Sources: semver, Packages: []Package{{Name: "rg", Source: "cargo", Version: ">=1:2"}}}, "version scheme semver"},
+		{"alternative scheme", Config{Sources: semver, Packages: []Package{{Name: "rg", Version: ">=1:2", Alternatives: []Alternative{{Source: "apt"}, {Source: "cargo"}}}}}, "version scheme semver"},
+		{"tilde string", Config{CustomPackages: []CustomPackage{{Name: "tool", Version: "~abc", VersionScheme: "string"}}}, "~ needs a numeric version"},
+		{"own scheme", Config{Sources: semver, Packages: []Package{{Name: "rg", Source: "cargo", Version: ">=1:2", VersionScheme: "debian"}}}, ""},
+	}
+	for _, c := range cases {
+		err := ValidateVersionConstraints(c.cfg)
+		if c.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestLoadFromFiles_RejectsInvalidVersion(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "a.yaml")
+	writeFile(t, f, "packages:\n  - name: htop\n    source: apt\n    version: \">=2.x.y garbage !!\"\nsources:\n  - name: apt\n")
+	if _, err := LoadFromFiles([]string{f}); err == nil || !strings.Contains(err.Error(), `invalid version: package "htop"`) {
+		t.Fatalf("expected version error, got %v", err)
+	}
+}
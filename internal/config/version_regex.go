@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ValidateVersionRegex checks that every version_regex compiles and names the
+// group holding the version.
+func ValidateVersionRegex(cfg Config) error {
+	for _, s := range cfg.Sources {
+		if err := validateVersionRegex("source", s.Name, s.VersionRegex); err != nil {
+			return err
+		}
+	}
+	for _, p := range cfg.Packages {
+		if err := validateVersionRegex("package", p.Name, p.VersionRegex); err != nil {
+			return err
+		}
+	}
+	for _, cp := range cfg.CustomPackages {
+		if err := validateVersionRegex("custom_package", cp.Name, cp.VersionRegex); err != nil {
+			return err
+		}
+	}
+	for _, gp := range cfg.GithubReleasePackages {
+		if err := validateVersionRegex("github_release_package", gp.Name, gp.VersionRegex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateVersionRegex(kind, name, expr string) error {
+	if expr == "" {
+		return nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid version_regex: %s %q: %w", kind, name, err)
+	}
+	if re.SubexpIndex("version") < 0 {
+		return fmt.Errorf("invalid version_regex: %s %q must name its capture group (?P<version>...)", kind, name)
+	}
+	return nil
+}
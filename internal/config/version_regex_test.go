@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateVersionRegex(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"named group", Config{CustomPackages: []CustomPackage{{Name: "tool", VersionRegex: `tool (?P<version>\d+\.\d+)`}}}, ""},
+		{"unnamed group", Config{Packages: []Package{{Name: "git", VersionRegex: `(\d+)`}}}, "(?P<version>...)"},
+		{"does not compile", Config{Sources: []Source{{Name: "apt", VersionRegex: `(?P<version>[`}}}, "invalid version_regex"},
+	}
+	for _, c := range cases {
+		err := ValidateVersionRegex(c.cfg)
+		if c.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StringList is a list of strings that may be written as a single string.
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*l = StringList{value.Value}
+		return nil
+	case yaml.SequenceNode:
+		var parts []string
+		if err := value.Decode(&parts); err != nil {
+			return err
+		}
+		*l = StringList(parts)
+		return nil
+	default:
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+}
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = StringList{s}
+		return nil
+	}
+	var parts []string
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+	*l = StringList(parts)
+	return nil
+}
+
+// When restricts a package to the hosts matching every condition it sets.
+// A condition with several values matches when any of them does.
+type When struct {
+	OS         StringList        `mapstructure:"os" yaml:"os" json:"os,omitempty"`
+	Distro     StringList        `mapstructure:"distro" yaml:"distro" json:"distro,omitempty"`
+	DistroLike StringList        `mapstructure:"distro_like" yaml:"distro_like" json:"distro_like,omitempty"`
+	Arch       StringList        `mapstructure:"arch" yaml:"arch" json:"arch,omitempty"`
+	Hostname   StringList        `mapstructure:"hostname" yaml:"hostname" json:"hostname,omitempty"`
+	Env        map[string]string `mapstructure:"env" yaml:"env" json:"env,omitempty"`
+}
+
+var whenKeys = map[string]bool{"os": true, "distro": true, "distro_like": true, "arch": true, "hostname": true, "env": true}
+
+// UnmarshalYAML rejects unknown conditions, which would otherwise be ignored
+// and make the package apply everywhere.
+func (w *When) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("when must be a mapping")
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if k := value.Content[i].Value; !whenKeys[k] {
+			return fmt.Errorf("line %d: unknown when condition %q", value.Content[i].Line, k)
+		}
+	}
+	type plain When
+	return value.Decode((*plain)(w))
+}
+
+// Host describes the machine that when conditions are matched against.
+type Host struct {
+	OS         string
+	Arch       string
+	Distro     string
+	DistroLike []string
+	Hostname   string
+	LookupEnv  func(string) (string, bool)
+}
+
+// CurrentHost describes the running machine. Distro and DistroLike come from
+// the ID and ID_LIKE fields of /etc/os-release and are empty elsewhere.
+func CurrentHost() Host {
+	h := Host{OS: runtime.GOOS, Arch: runtime.GOARCH, LookupEnv: os.LookupEnv}
+	h.Hostname, _ = os.Hostname()
+	if f, err := os.Open("/etc/os-release"); err == nil {
+		defer f.Close()
+		h.Distro, h.DistroLike = parseOSRelease(bufio.NewScanner(f))
+	}
+	return h
+}
+
+func parseOSRelease(sc *bufio.Scanner) (string, []string) {
+	var id string
+	var like []string
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		v = strings.Trim(v, `"'`)
+		switch k {
+		case "ID":
+			id = strings.ToLower(v)
+		case "ID_LIKE":
+			like = strings.Fields(strings.ToLower(v))
+		}
+	}
+	return id, like
+}
+
+// Matches reports whether h satisfies every condition of w. A nil When
+// matches every host. distro_like also matches the distribution itself, so
+// "debian" covers Debian as well as Ubuntu. Hostname and env values are globs.
+func (w *When) Matches(h Host) bool {
+	if w == nil {
+		return true
+	}
+	if !matchAny(w.OS, h.OS) || !matchAny(w.Arch, h.Arch) || !matchAny(w.Distro, h.Distro) {
+		return false
+	}
+	if len(w.DistroLike) > 0 {
+		ok := false
+		for _, d := range append([]string{h.Distro}, h.DistroLike...) {
+			if d != "" && matchAny(w.DistroLike, d) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(w.Hostname) > 0 {
+		ok := false
+		for _, p := range w.Hostname {
+			if m, _ := path.Match(p, h.Hostname); m {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for k, p := range w.Env {
+		v, set := "", false
+		if h.LookupEnv != nil {
+			v, set = h.LookupEnv(k)
+		}
+		if !set {
+			return false
+		}
+		if m, _ := path.Match(p, v); !m {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(values StringList, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, want := range values {
+		if strings.EqualFold(want, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterForHost drops the packages and overrides whose when conditions do
+// not match h.
+func FilterForHost(cfg Config, h Host) Config {
+	if cfg.Overrides != nil {
+		o := FilterForHost(Config{Packages: cfg.Overrides.Packages, CustomPackages: cfg.Overrides.CustomPackages, GithubReleasePackages: cfg.Overrides.GithubReleasePackages}, h)
+		cfg.Overrides = &Overrides{Packages: o.Packages, CustomPackages: o.CustomPackages, GithubReleasePackages: o.GithubReleasePackages}
+	}
+	pkgs := cfg.Packages[:0:0]
+	for _, p := range cfg.Packages {
+		if p.When.Matches(h) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	custom := cfg.CustomPackages[:0:0]
+	for _, p := range cfg.CustomPackages {
+		if p.When.Matches(h) {
+			custom = append(custom, p)
+		}
+	}
+	gh := cfg.GithubReleasePackages[:0:0]
+	for _, p := range cfg.GithubReleasePackages {
+		if p.When.Matches(h) {
+			gh = append(gh, p)
+		}
+	}
+	cfg.Packages, cfg.CustomPackages, cfg.GithubReleasePackages = pkgs, custom, gh
+	return cfg
+}
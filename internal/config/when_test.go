@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func testHost() Host {
+	env := map[string]string{"WORK": "1"}
+	return Host{
+		OS:         "linux",
+		Arch:       "arm64",
+		Distro:     "ubuntu",
+		DistroLike: []string{"debian"},
+		Hostname:   "pi-kitchen",
+		LookupEnv: func(k string) (string, bool) {
+			v, ok := env[k]
+			return v, ok
+		},
+	}
+}
+
+func TestWhen_Matches(t *testing.T) {
+	cases := []struct {
+		when string
+		want bool
+	}{
+		{"os: linux", true},
+		{"os: [darwin, windows]", false},
+		{"distro: ubuntu", true},
+		{"distro: debian", false},
+		{"distro_like: debian", true},
+		{"distro_like: ubuntu", true},
+		{"distro_like: arch", false},
+		{"arch: [amd64, arm64]", true},
+		{"hostname: pi-*", true},
+		{"hostname: laptop-*", false},
+		{"env: {WORK: '1'}", true},
+		{"env: {WORK: '*'}", true},
+		{"env: {HOME_LAB: '*'}", false},
+		{"{os: linux, arch: amd64}", false},
+	}
+	for _, c := range cases {
+		var w When
+		if err := yaml.Unmarshal([]byte(c.when), &w); err != nil {
+			t.Fatalf("%s: %v", c.when, err)
+		}
+		if got := w.Matches(testHost()); got != c.want {
+			t.Errorf("%s: Matches = %v, want %v", c.when, got, c.want)
+		}
+	}
+	var nilWhen *When
+	if !nilWhen.Matches(testHost()) {
+		t.Fatal("nil when should match every host")
+	}
+}
+
+func TestWhen_UnknownCondition(t *testing.T) {
+	var p Package
+	err := yaml.Unmarshal([]byte("name: x\nsource: apt\nwhen:\n  oss: linux\n"), &p)
+	if err == nil || !strings.Contains(err.Error(), `unknown when condition "oss"`) {
+		t.Fatalf("expected unknown condition error, got %v", err)
+	}
+}
+
+func TestParseOSRelease(t *testing.T) {
+	id, like := parseOSRelease(bufio.NewScanner(strings.NewReader("NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=\"debian\"\n")))
+	if id != "ubuntu" || len(like) != 1 || like[0] != "debian" {
+		t.Fatalf("got %q %v", id, like)
+	}
+}
+
+func TestLoadDefaultsAndFiles_FiltersByHostBeforeDuplicates(t *testing.T) {
+	prev := host
+	host = testHost
+	defer func() { host = prev }()
+
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.yaml")
+	os.WriteFile(f, []byte(`packages:
+  - name: neovim
+    source: pacman
+    when: {distro: arch}
+  - name: neovim
+    source: apt
+    when: {distro_like: debian}
+custom_packages:
+  - name: work-vpn
+    get_installed_version: "true"
+    when:
+      env: {WORK: "1"}
+      hostname: laptop-*
+`), 0o644)
+	cfg, err := LoadDefaultsAndFiles(nil, []string{f})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.Packages) != 1 || cfg.Packages[0].Source != "apt" {
+		t.Fatalf("packages = %+v", cfg.Packages)
+	}
+	for _, c := range cfg.CustomPackages {
+		if c.Name == "work-vpn" {
+			t.Fatal("work-vpn should not apply to this host")
+		}
+	}
+}
@@ -2,15 +2,23 @@ package executil
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/the-gopak/gopak-cli/internal/config"
 )
 
+// TimeoutCode is the exit code reported for commands stopped by their
+// timeout, as timeout(1) does.
+const TimeoutCode = 124
+
 type Result struct {
 	Stdout string
 	Stderr string
@@ -18,28 +26,198 @@ type Result struct {
 }
 
 func RunShell(c config.Command) Result {
-	final := c.Command
-	if c.RequireRoot && runtime.GOOS != "windows" && os.Geteuid() != 0 {
-		esc := strings.ReplaceAll(c.Command, "'", "'\"'\"'")
-		final = fmt.Sprintf("sudo bash -ceu '%s'", esc)
-	}
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", final)
-	} else {
-		cmd = exec.Command("bash", "-ceu", final)
-	}
 	var out, errb bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &errb
-	err := cmd.Run()
-	code := 0
+	p, err := Command(c, false)
 	if err != nil {
-		if e, ok := err.(*exec.ExitError); ok {
-			code = e.ExitCode()
-		} else {
+		return Result{Stderr: err.Error() + "\n", Code: 1}
+	}
+	p.Stdout = &out
+	p.Stderr = &errb
+	code := 0
+	if err := p.Run(); err != nil {
+		var ee *exec.ExitError
+		switch {
+		case errors.Is(err, ErrTimeout):
+			code = TimeoutCode
+			errb.WriteString(err.Error() + "\n")
+		case errors.As(err, &ee):
+			code = ee.ExitCode()
+		default:
 			code = 1
 		}
 	}
 	return Result{Stdout: out.String(), Stderr: errb.String(), Code: code}
 }
+
+// ErrTimeout is returned by Process.Run for commands stopped by their
+// timeout.
+var ErrTimeout = errors.New("timed out")
+
+// Process is a configured command ready to run.
+type Process struct {
+	*exec.Cmd
+	timeout  string
+	cancel   context.CancelFunc
+	timedOut bool
+	// envFile holds the environment of a sudo command until its shell
+	// loads it.
+	envFile string
+}
+
+// Command builds the process that runs c in its shell, working directory and
+// environment. Commands that require root run through sudo unless the process
+// already is root; with nonInteractive sudo must not prompt, as credentials
+// were obtained beforehand. Under sudo, env is written to a private file that
+// the root shell loads and deletes, so that neither it nor the secrets it
+// carries appear in the argument list. Secrets referenced by c are resolved
+// and passed in the environment, and {{name}} escapes become {name}.
+func Command(c config.Command, nonInteractive bool) (*Process, error) {
+	c, err := injectSecrets(unescape(c))
+	if err != nil {
+		return nil, err
+	}
+	for k := range c.Env {
+		if !config.ValidEnvName(k) {
+			return nil, fmt.Errorf("invalid env name %q", k)
+		}
+	}
+	timeout, err := c.ParsedTimeout()
+	if err != nil {
+		return nil, err
+	}
+	args, err := shellArgs(c)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	env := envList(c.Env)
+	p := &Process{timeout: c.Timeout, cancel: cancel}
+	if c.RequireRoot && runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		sudo := []string{}
+		if nonInteractive {
+			sudo = append(sudo, "-n")
+		}
+		if len(c.Env) > 0 {
+			f, err := writeEnvFile(c.Env)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			p.envFile = f
+			sudo = append(sudo, "sh", "-ec", envFileLoader, "sh", f)
+		}
+		p.Cmd = exec.CommandContext(ctx, "sudo", append(sudo, args...)...)
+	} else {
+		p.Cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+		if len(env) > 0 {
+			p.Env = append(os.Environ(), env...)
+		}
+	}
+	p.Dir = c.Cwd
+	p.Cmd.Cancel = func() error {
+		p.timedOut = true
+		return interrupt(p.Process)
+	}
+	p.WaitDelay = 5 * time.Second
+	return p, nil
+}
+
+// Run runs the process and reports a stop by the timeout as ErrTimeout.
+func (p *Process) Run() error {
+	defer p.cancel()
+	if p.envFile != "" {
+		defer os.Remove(p.envFile)
+	}
+	err := p.Cmd.Run()
+	if err != nil && p.timedOut {
+		return fmt.Errorf("%w after %s", ErrTimeout, p.timeout)
+	}
+	return err
+}
+
+// unescape turns the {{name}} escapes in the command text, cwd and env
+// values of c into literal braces.
+func unescape(c config.Command) config.Command {
+	c.Command = config.UnescapeRefs(c.Command)
+	c.Cwd = config.UnescapeRefs(c.Cwd)
+	if c.Env != nil {
+		env := make(map[string]string, len(c.Env))
+		for k, v := range c.Env {
+			env[k] = config.UnescapeRefs(v)
+		}
+		c.Env = env
+	}
+	return c
+}
+
+// PosixShell reports whether the command text of c runs in a POSIX shell,
+// which is the default everywhere except Windows.
+func PosixShell(c config.Command) bool {
+	switch c.Shell {
+	case "":
+		return runtime.GOOS != "windows"
+	case "bash", "zsh", "sh":
+		return true
+	}
+	return false
+}
+
+// shellArgs returns the program and arguments that run the command text of c.
+func shellArgs(c config.Command) ([]string, error) {
+	switch c.Shell {
+	case "":
+		if runtime.GOOS == "windows" {
+			return []string{"cmd", "/C", c.Command}, nil
+		}
+		return []string{"bash", "-ceu", c.Command}, nil
+	case "bash", "zsh":
+		return []string{c.Shell, "-ceu", c.Command}, nil
+	case "sh":
+		return []string{"sh", "-ceu", c.Command}, nil
+	case "pwsh":
+		return []string{"pwsh", "-NoProfile", "-NonInteractive", "-Command", c.Command}, nil
+	}
+	return nil, fmt.Errorf("unsupported shell %q", c.Shell)
+}
+
+// envFileLoader is run by sh under sudo with the env file and the command
+// as arguments: it exports the variables of the file, deletes it and runs
+// the command.
+const envFileLoader = `set -a; . "$1"; rm -f -- "$1"; shift; exec "$@"`
+
+// writeEnvFile writes env to a file readable only by the current user, as
+// shell assignments with single-quoted values.
+func writeEnvFile(env map[string]string) (string, error) {
+	f, err := os.CreateTemp("", "gopak-env-*")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, kv := range envList(env) {
+		k, v, _ := strings.Cut(kv, "=")
+		b.WriteString(k + "='" + strings.ReplaceAll(v, "'", `'\''`) + "'\n")
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// envList returns env as sorted KEY=value pairs.
+func envList(env map[string]string) []string {
+	out := make([]string, 0, len(env))
+	for k, v := range env {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,143 @@
+package executil
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+)
+
+func TestRunShell_EnvCwdShell(t *testing.T) {
+	dir := t.TempDir()
+	res := RunShell(config.Command{
+		Command: `echo "$GREETING $(pwd)"`,
+		Env:     map[string]string{"GREETING": "hello"},
+		Cwd:     dir,
+		Shell:   "sh",
+	})
+	if res.Code != 0 {
+		t.Fatalf("exit %d: %s", res.Code, res.Stderr)
+	}
+	if got := strings.TrimSpace(res.Stdout); got != "hello "+dir {
+		t.Fatalf("stdout = %q", got)
+	}
+}
+
+func TestRunShell_Timeout(t *testing.T) {
+	res := RunShell(config.Command{Command: "sleep 5", Timeout: "100ms"})
+	if res.Code != TimeoutCode || !strings.Contains(res.Stderr, "timed out after 100ms") {
+		t.Fatalf("code %d, stderr %q", res.Code, res.Stderr)
+	}
+}
+
+func TestRunShell_InvalidOptions(t *testing.T) {
+	for _, c := range []config.Command{
+		{Command: "true", Shell: "fish"},
+		{Command: "true", Timeout: "soon"},
+		{Command: "true", Env: map[string]string{"-i": "x"}},
+	} {
+		if res := RunShell(c); res.Code == 0 {
+			t.Fatalf("%+v: expected failure", c)
+		}
+	}
+}
+
+func TestCommand_SudoKeepsEnv(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("sudo is not used when running as root")
+	}
+	p, err := Command(config.Command{Command: "make install", RequireRoot: true, Cwd: "/tmp"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sudo", "-n", "bash", "-ceu", "make install"}
+	if !reflect.DeepEqual(p.Args, want) || p.Dir != "/tmp" {
+		t.Fatalf("args = %v, dir = %q", p.Args, p.Dir)
+	}
+}
+
+func TestCommand_SudoSecretsNotInArgs(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("sudo is not used when running as root")
+	}
+	UseSecrets(func(name string) (string, error) { return "s3cr3t-'" + name, nil })
+	defer UseSecrets(nil)
+	p, err := Command(config.Command{
+		Command:     `echo "$A ${secret:artifacts} $URL"`,
+		RequireRoot: true,
+		Env:         map[string]string{"A": "1", "URL": "https://x/?t=${secret:token}"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(p.envFile)
+	if strings.Contains(strings.Join(p.Args, " "), "s3cr3t") {
+		t.Fatalf("secret in args: %v", p.Args)
+	}
+	if p.Args[0] != "sudo" || p.Args[1] != "-n" {
+		t.Fatalf("args = %v", p.Args)
+	}
+	st, err := os.Stat(p.envFile)
+	if err != nil || st.Mode().Perm() != 0o600 {
+		t.Fatalf("env file: %v, %v", st, err)
+	}
+
+	// Run what sudo would run, as the current user.
+	out, err := exec.Command(p.Args[2], p.Args[3:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "1 s3cr3t-'artifacts https://x/?t=s3cr3t-'token" {
+		t.Fatalf("output = %q", got)
+	}
+	if _, err := os.Stat(p.envFile); !os.IsNotExist(err) {
+		t.Fatalf("env file was not deleted: %v", err)
+	}
+}
+
+func TestCommand_SecretsPassedInEnv(t *testing.T) {
+	UseSecrets(func(name string) (string, error) { return "s3cr3t-" + name, nil })
+	defer UseSecrets(nil)
+	p, err := Command(config.Command{
+		Command: `curl -H "Authorization: ${secret:artifacts}" "$URL"`,
+		Env:     map[string]string{"URL": "https://x/?t=${secret:url-token}"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.Join(p.Args, " "), "s3cr3t") {
+		t.Fatalf("secret in args: %v", p.Args)
+	}
+	if !strings.Contains(p.Args[len(p.Args)-1], "${GOPAK_SECRET_ARTIFACTS}") {
+		t.Fatalf("args = %v", p.Args)
+	}
+	env := strings.Join(p.Env, "\n")
+	for _, want := range []string{"GOPAK_SECRET_ARTIFACTS=s3cr3t-artifacts", "URL=https://x/?t=s3cr3t-url-token"} {
+		if !strings.Contains(env, want) {
+			t.Fatalf("env lacks %s", want)
+		}
+	}
+	res := RunShell(config.Command{Command: `printf %s "${secret:tok}"`})
+	if res.Code != 0 || res.Stdout != "s3cr3t-tok" {
+		t.Fatalf("code %d, stdout %q, stderr %q", res.Code, res.Stdout, res.Stderr)
+	}
+}
+
+func TestCommand_SecretWithoutStore(t *testing.T) {
+	if res := RunShell(config.Command{Command: `echo ${secret:tok}`}); res.Code == 0 || !strings.Contains(res.Stderr, "no secret store") {
+		t.Fatalf("code %d, stderr %q", res.Code, res.Stderr)
+	}
+}
+
+func TestRunShell_EscapedBraces(t *testing.T) {
+	res := RunShell(config.Command{Command: `echo tool 1.2.3 | awk '{{print}}' && echo '{{version}}'`, Shell: "sh"})
+	if res.Code != 0 {
+		t.Fatalf("exit %d: %s", res.Code, res.Stderr)
+	}
+	if got := strings.TrimSpace(res.Stdout); got != "tool 1.2.3\n{version}" {
+		t.Fatalf("stdout = %q", got)
+	}
+}
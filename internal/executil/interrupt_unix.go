@@ -0,0 +1,13 @@
+//go:build !windows
+
+package executil
+
+import (
+	"os"
+	"syscall"
+)
+
+// interrupt asks p to stop. sudo relays SIGTERM to the command it runs.
+func interrupt(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package executil
+
+import "os"
+
+// interrupt stops p; Windows has no signal to ask politely.
+func interrupt(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,80 @@
+package executil
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+)
+
+// secretLookup resolves secret names; nil until UseSecrets is called.
+var secretLookup func(name string) (string, error)
+
+// UseSecrets sets the function that resolves ${secret:name} references in
+// the commands run from now on.
+func UseSecrets(lookup func(name string) (string, error)) { secretLookup = lookup }
+
+// SecretEnv is the environment variable that carries the secret name.
+func SecretEnv(name string) string {
+	return "GOPAK_SECRET_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// injectSecrets resolves the secrets referenced by c. The command text never
+// contains a value: each reference becomes a reference to the environment
+// variable SecretEnv(name) of its shell, and the value is added to the
+// environment. References in env values are replaced by the value.
+func injectSecrets(c config.Command) (config.Command, error) {
+	refs := config.SecretRefs(c.Command)
+	for _, v := range c.Env {
+		refs = append(refs, config.SecretRefs(v)...)
+	}
+	if len(refs) == 0 {
+		return c, nil
+	}
+	values := map[string]string{}
+	for _, name := range refs {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		if secretLookup == nil {
+			return c, fmt.Errorf("secret %q: no secret store configured", name)
+		}
+		v, err := secretLookup(name)
+		if err != nil {
+			return c, err
+		}
+		values[name] = v
+	}
+	env := make(map[string]string, len(c.Env)+len(values))
+	for k, v := range c.Env {
+		env[k] = config.ReplaceSecretRefs(v, func(name string) string { return values[name] })
+	}
+	for _, name := range config.SecretRefs(c.Command) {
+		env[SecretEnv(name)] = values[name]
+	}
+	c.Env = env
+	c.Command = config.ReplaceSecretRefs(c.Command, func(name string) string {
+		return envRef(c.Shell, SecretEnv(name))
+	})
+	return c, nil
+}
+
+// envRef is how shell refers to the environment variable name.
+func envRef(shell, name string) string {
+	switch {
+	case shell == "pwsh":
+		return "${env:" + name + "}"
+	case shell == "" && runtime.GOOS == "windows":
+		return "%" + name + "%"
+	}
+	return "${" + name + "}"
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,12 +16,23 @@ type Asset struct {
 	Name               string `json:"name"`
 	BrowserDownloadURL string `json:"browser_download_url"`
 	Size               int64  `json:"size"`
+	Digest             string `json:"digest"`
+}
+
+// SHA256 returns the checksum GitHub publishes for the asset, if any.
+func (a Asset) SHA256() string {
+	if v, ok := strings.CutPrefix(a.Digest, "sha256:"); ok {
+		return v
+	}
+	return ""
 }
 
 type Release struct {
 	TagName     string  `json:"tag_name"`
 	Name        string  `json:"name"`
 	PublishedAt string  `json:"published_at"`
+	Draft       bool    `json:"draft"`
+	Prerelease  bool    `json:"prerelease"`
 	Assets      []Asset `json:"assets"`
 }
 
@@ -38,32 +50,56 @@ func NewClient() *Client {
 }
 
 func (c *Client) GetLatestRelease(repo string) (*Release, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
+	return c.getRelease(fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo))
+}
+
+func (c *Client) GetReleaseByTag(repo, tag string) (*Release, error) {
+	return c.getRelease(fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, url.PathEscape(tag)))
+}
+
+// ListReleases returns the most recent published releases of repo, newest
+// first. Drafts are skipped; prereleases are kept and flagged.
+func (c *Client) ListReleases(repo string) ([]Release, error) {
+	var releases []Release
+	if err := c.getJSON(fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=100", repo), &releases); err != nil {
+		return nil, err
+	}
+	out := releases[:0]
+	for _, r := range releases {
+		if !r.Draft {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (c *Client) getRelease(url string) (*Release, error) {
+	var release Release
+	if err := c.getJSON(url, &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+func (c *Client) getJSON(url string, v any) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
-
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %d %s", resp.StatusCode, string(body))
+		return fmt.Errorf("GitHub API error: %d %s", resp.StatusCode, string(body))
 	}
-
-	var release Release
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return nil, err
-	}
-	return &release, nil
+	return json.NewDecoder(resp.Body).Decode(v)
 }
 
 func (c *Client) FindAsset(release *Release, pattern string) (*Asset, error) {
@@ -1,6 +1,11 @@
 package github
 
-import "testing"
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
 
 func TestGlobMatch(t *testing.T) {
 	tests := []struct {
@@ -31,3 +36,25 @@ func TestGlobMatch(t *testing.T) {
 		})
 	}
 }
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestGetReleaseByTag_EscapesTag(t *testing.T) {
+	var got string
+	c := &Client{httpClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.URL.RequestURI()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"tag_name":"release/1.0+build#2"}`))}, nil
+	})}}
+	rel, err := c.GetReleaseByTag("org/tool", "release/1.0+build#2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/repos/org/tool/releases/tags/release%2F1.0+build%232"; got != want {
+		t.Fatalf("requested %s, want %s", got, want)
+	}
+	if rel.TagName != "release/1.0+build#2" {
+		t.Fatalf("tag = %q", rel.TagName)
+	}
+}
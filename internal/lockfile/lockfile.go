@@ -0,0 +1,105 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileName is the name of the lockfile written next to the configuration.
+const FileName = "gopak.lock"
+
+// Entry pins the version of one package. GitHub release packages also pin
+// the exact asset and its checksum.
+type Entry struct {
+	Kind    string `json:"kind"`
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version"`
+	Tag     string `json:"tag,omitempty"`
+	Asset   string `json:"asset,omitempty"`
+	URL     string `json:"url,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+type Lock struct {
+	Packages map[string]Entry `json:"packages"`
+}
+
+type Lockfile struct {
+	path string
+	lock Lock
+	mu   sync.RWMutex
+}
+
+// Load reads the lockfile from configDir. A missing file yields an empty
+// lockfile; use Exists to tell the two apart.
+func Load(configDir string) (*Lockfile, error) {
+	l := &Lockfile{
+		path: filepath.Join(configDir, FileName),
+		lock: Lock{Packages: make(map[string]Entry)},
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.lock); err != nil {
+		return nil, err
+	}
+	if l.lock.Packages == nil {
+		l.lock.Packages = make(map[string]Entry)
+	}
+	return l, nil
+}
+
+func (l *Lockfile) Path() string { return l.path }
+
+func (l *Lockfile) Exists() bool {
+	_, err := os.Stat(l.path)
+	return err == nil
+}
+
+func (l *Lockfile) Save() error {
+	l.mu.RLock()
+	data, err := json.MarshalIndent(l.lock, "", "  ")
+	l.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, append(data, '\n'), 0o644)
+}
+
+func (l *Lockfile) Get(name string) (Entry, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	e, ok := l.lock.Packages[name]
+	return e, ok
+}
+
+func (l *Lockfile) Set(name string, e Entry) {
+	l.mu.Lock()
+	l.lock.Packages[name] = e
+	l.mu.Unlock()
+}
+
+func (l *Lockfile) Delete(name string) {
+	l.mu.Lock()
+	delete(l.lock.Packages, name)
+	l.mu.Unlock()
+}
+
+// Names returns the names of all locked packages in sorted order.
+func (l *Lockfile) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.lock.Packages))
+	for name := range l.lock.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,49 @@
+package lockfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLoad_MissingFileIsEmpty(t *testing.T) {
+	l, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if l.Exists() {
+		t.Fatal("lockfile should not exist yet")
+	}
+	if len(l.Names()) != 0 {
+		t.Fatalf("expected no entries, got %v", l.Names())
+	}
+}
+
+func TestSaveAndReload(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := Load(dir)
+	want := Entry{Kind: "github", Version: "v1.2.0", Tag: "v1.2.0", Asset: "tool-linux.tar.gz", URL: "https://example.com/tool-linux.tar.gz", SHA256: "abc"}
+	l.Set("tool", want)
+	l.Set("git", Entry{Kind: "source", Source: "apt", Version: "1:2.43.0-1"})
+	if err := l.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	again, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !again.Exists() {
+		t.Fatal("lockfile should exist after save")
+	}
+	got, ok := again.Get("tool")
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	if names := again.Names(); !reflect.DeepEqual(names, []string{"git", "tool"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	again.Delete("git")
+	if _, ok := again.Get("git"); ok {
+		t.Fatal("entry should be deleted")
+	}
+}
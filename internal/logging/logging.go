@@ -1,10 +1,14 @@
 package logging
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 var logfile *os.File
@@ -28,21 +32,25 @@ func Close() {
 func color(code, s string) string { return "\x1b[" + code + "m" + s + "\x1b[0m" }
 
 func Info(msg string) {
+	msg = Redact(msg)
 	fmt.Println(msg)
 	log.Println(msg)
 }
 
 func Success(msg string) {
+	msg = Redact(msg)
 	fmt.Println(color("32", msg))
 	log.Println(msg)
 }
 
 func Error(msg string) {
+	msg = Redact(msg)
 	_, _ = fmt.Fprintln(os.Stderr, color("31", msg))
 	log.Println(msg)
 }
 
 func Gray(msg string) {
+	msg = Redact(msg)
 	fmt.Println(color("90", msg))
 	log.Println(msg)
 }
@@ -55,6 +63,69 @@ func Debug(msg string) {
 	if !verbose {
 		return
 	}
+	msg = Redact(msg)
 	fmt.Println(color("90", msg))
 	log.Println("[DEBUG] " + msg)
 }
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// AddSecret masks value in everything printed or logged from now on.
+func AddSecret(value string) {
+	if value == "" {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = append(secrets, value)
+}
+
+// Redact replaces the values passed to AddSecret in s with ***.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, v := range secrets {
+		s = strings.ReplaceAll(s, v, "***")
+	}
+	return s
+}
+
+// RedactWriter passes output on to w with secrets masked. Output is held
+// until a line ends so that a secret split across writes is still masked;
+// Flush writes what is left.
+type RedactWriter struct {
+	w   io.Writer
+	mu  sync.Mutex
+	buf []byte
+}
+
+func NewRedactWriter(w io.Writer) *RedactWriter { return &RedactWriter{w: w} }
+
+func (r *RedactWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf = append(r.buf, p...)
+	if i := bytes.LastIndexAny(r.buf, "\r\n"); i >= 0 {
+		line := r.buf[:i+1]
+		if _, err := io.WriteString(r.w, Redact(string(line))); err != nil {
+			return 0, err
+		}
+		r.buf = append(r.buf[:0], r.buf[i+1:]...)
+	}
+	return len(p), nil
+}
+
+// Flush writes the output held back since the last line end.
+func (r *RedactWriter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(r.w, Redact(string(r.buf)))
+	r.buf = r.buf[:0]
+	return err
+}
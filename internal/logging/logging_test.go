@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactWriter_SecretSplitAcrossWrites(t *testing.T) {
+	AddSecret("hunter2")
+	var out strings.Builder
+	w := NewRedactWriter(&out)
+	for _, s := range []string{"token hun", "ter2 ok\nnext hunt", "er2"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := out.String(); got != "token *** ok\n" {
+		t.Fatalf("before Flush: %q", got)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "token *** ok\nnext ***" {
+		t.Fatalf("after Flush: %q", got)
+	}
+}
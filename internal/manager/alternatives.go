@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/logging"
+	"github.com/the-gopak/gopak-cli/internal/state"
+)
+
+// lookPath finds the program a source runs; tests replace it.
+var lookPath = exec.LookPath
+
+// sourceProgram is the program a source runs: the first word of its
+// list_installed command, or of install when it has none.
+func sourceProgram(s config.Source) string {
+	for _, c := range []config.Command{s.ListInstalled, s.Install} {
+		if fields := strings.Fields(c.Command); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// chooseAlternatives replaces every package with alternatives by the
+// alternative chosen for this host: the one recorded in the state when the
+// package was installed, otherwise the first whose source is configured and
+// whose program is on PATH. GitHub releases are always available. Packages
+// without a usable alternative are dropped. It also returns the names to pass
+// to sources for packages they know under another name.
+func chooseAlternatives(cfg config.Config, recorded func(name string) (state.PackageState, bool)) (config.Config, map[string]string) {
+	names := map[string]string{}
+	has := false
+	for _, p := range cfg.Packages {
+		has = has || len(p.Alternatives) > 0
+	}
+	if !has {
+		return cfg, names
+	}
+	sources := map[string]config.Source{}
+	for _, s := range cfg.Sources {
+		sources[s.Name] = s
+	}
+	available := map[string]bool{}
+	isAvailable := func(source string) bool {
+		s, ok := sources[source]
+		if !ok {
+			return false
+		}
+		if v, ok := available[source]; ok {
+			return v
+		}
+		prog := sourceProgram(s)
+		_, err := lookPath(prog)
+		available[source] = prog != "" && err == nil
+		return available[source]
+	}
+
+	out := cfg
+	out.Packages = nil
+	out.GithubReleasePackages = append([]config.GithubReleasePackage{}, cfg.GithubReleasePackages...)
+	for _, p := range cfg.Packages {
+		if len(p.Alternatives) == 0 {
+			out.Packages = append(out.Packages, p)
+			continue
+		}
+		chosen := -1
+		if ps, ok := recorded(p.Name); ok {
+			for i, a := range p.Alternatives {
+				if (a.Github != "" && ps.Kind == "github") || (a.Source != "" && a.Source == ps.Source) {
+					chosen = i
+					break
+				}
+			}
+		}
+		for i, a := range p.Alternatives {
+			if chosen >= 0 {
+				break
+			}
+			if a.Github != "" || isAvailable(a.Source) {
+				chosen = i
+			}
+		}
+		if chosen < 0 {
+			logging.Debug(fmt.Sprintf("alternatives: no source of %s is available on this host", p.Name))
+			continue
+		}
+		a := p.Alternatives[chosen]
+		if a.Github != "" {
+			out.GithubReleasePackages = append(out.GithubReleasePackages, config.GithubReleasePackage{
+				Name: p.Name, Version: p.Version, VersionScheme: p.VersionScheme, VersionRegex: p.VersionRegex,
+				Executable: p.Executable, Repo: a.Github, AssetPattern: a.AssetPattern,
+				GetInstalledVersion: a.GetInstalledVersion, PostInstall: a.PostInstall, Remove: a.Remove,
+				DependsOn: p.DependsOn, Tags: p.Tags, When: p.When,
+			})
+			continue
+		}
+		p.Source, p.Alternatives = a.Source, nil
+		if a.Name != "" && a.Name != p.Name {
+			names[p.Name] = a.Name
+		}
+		out.Packages = append(out.Packages, p)
+	}
+	return out, names
+}
+
+// sourcePackageName is the name the source of a package knows it by.
+func (m *Manager) sourcePackageName(name string) string {
+	if n, ok := m.sourceNames[name]; ok {
+		return n
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/state"
+)
+
+func fakeLookPath(t *testing.T, found ...string) {
+	t.Helper()
+	orig := lookPath
+	lookPath = func(file string) (string, error) {
+		for _, f := range found {
+			if f == file {
+				return "/usr/bin/" + f, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() { lookPath = orig })
+}
+
+func alternativesConfig(log string) config.Config {
+	return config.Config{
+		Sources: []config.Source{
+			{
+				Name:                "apt",
+				ListInstalled:       config.Command{Command: "dpkg-query -W"},
+				Install:             config.Command{Command: "echo install {package} >> " + log},
+				Remove:              config.Command{Command: "echo remove {package} >> " + log},
+				GetInstalledVersion: config.Command{Command: "echo 8.7.0"},
+			},
+			{Name: "pacman", Install: config.Command{Command: "pacman -S {package}"}},
+		},
+		Packages: []config.Package{{
+			Name: "fd",
+			Alternatives: []config.Alternative{
+				{Source: "pacman"},
+				{Source: "apt", Name: "fd-find"},
+				{Github: "sharkdp/fd", AssetPattern: "fd-.*-x86_64-unknown-linux-gnu.tar.gz"},
+			},
+		}},
+	}
+}
+
+func TestAlternatives_FirstAvailable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	log := filepath.Join(t.TempDir(), "log")
+	fakeLookPath(t, "dpkg-query")
+	m := New(alternativesConfig(log))
+	st := newStateForTest(t)
+	m.UseState(st)
+	if k, err := m.KeyForName("fd"); err != nil || k.Kind != "source" || k.Source != "apt" {
+		t.Fatalf("KeyForName = %+v, %v", k, err)
+	}
+	if err := m.Install("fd"); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if err := m.Remove("fd"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(b)); got != "install fd-find\nremove fd-find" {
+		t.Fatalf("commands ran:\n%s", got)
+	}
+
+	fakeLookPath(t)
+	m = New(alternativesConfig(log))
+	if k, err := m.KeyForName("fd"); err != nil || k.Kind != "github" {
+		t.Fatalf("without apt or pacman, KeyForName = %+v, %v", k, err)
+	}
+	if gp := m.githubByName("fd"); gp.Repo != "sharkdp/fd" || gp.AssetPattern == "" {
+		t.Fatalf("github alternative = %+v", gp)
+	}
+}
+
+func TestAlternatives_KeepRecordedChoice(t *testing.T) {
+	fakeLookPath(t, "dpkg-query", "pacman")
+	st := newStateForTest(t)
+	if err := st.SetPackageState("fd", state.PackageState{Kind: "source", Source: "apt", Version: "8.7.0"}); err != nil {
+		t.Fatal(err)
+	}
+	m := New(alternativesConfig("log"))
+	if k, _ := m.KeyForName("fd"); k.Source != "pacman" {
+		t.Fatalf("first available source = %q, want pacman", k.Source)
+	}
+	m.UseState(st)
+	if k, _ := m.KeyForName("fd"); k.Source != "apt" {
+		t.Fatalf("recorded source = %q, want apt", k.Source)
+	}
+	if got := m.sourcePackageName("fd"); got != "fd-find" {
+		t.Fatalf("sourcePackageName = %q", got)
+	}
+}
+
+func TestAlternatives_NoneAvailable(t *testing.T) {
+	fakeLookPath(t)
+	cfg := alternativesConfig("log")
+	cfg.Packages[0].Alternatives = cfg.Packages[0].Alternatives[:2]
+	m := New(cfg)
+	if _, err := m.KeyForName("fd"); err == nil {
+		t.Fatal("a package without an available alternative must be skipped")
+	}
+}
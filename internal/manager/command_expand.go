@@ -5,11 +5,13 @@ import (
 	"strings"
 
 	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/logging"
 )
 
 const (
 	placeholderPackage     = "{package}"
 	placeholderPackageList = "{package_list}"
+	placeholderVersion     = "{version}"
 )
 
 // expandCommandForNames expands a command for the given package names.
@@ -27,8 +29,8 @@ func expandCommandForNames(cmd config.Command, names []string) (group bool, expa
 		return true, nil, fmt.Errorf("no package names provided")
 	}
 
-	hasPkg := strings.Contains(cmd.Command, placeholderPackage)
-	hasList := strings.Contains(cmd.Command, placeholderPackageList)
+	hasPkg := config.HasRef(cmd.Command, placeholderPackage)
+	hasList := config.HasRef(cmd.Command, placeholderPackageList)
 	if hasPkg && hasList {
 		return true, nil, fmt.Errorf("command contains both %s and %s", placeholderPackage, placeholderPackageList)
 	}
@@ -36,19 +38,19 @@ func expandCommandForNames(cmd config.Command, names []string) (group bool, expa
 	if hasPkg {
 		out := make([]config.Command, 0, len(names))
 		for _, n := range names {
-			out = append(out, config.Command{
-				Command:     strings.ReplaceAll(cmd.Command, placeholderPackage, n),
-				RequireRoot: cmd.RequireRoot,
-			})
+			c := cmd
+			c.Command = config.ReplaceRef(cmd.Command, placeholderPackage, n)
+			out = append(out, c)
 		}
 		return false, out, nil
 	}
 
 	cmdStr := cmd.Command
 	if hasList {
-		cmdStr = strings.ReplaceAll(cmdStr, placeholderPackageList, strings.Join(names, " "))
+		cmdStr = config.ReplaceRef(cmdStr, placeholderPackageList, strings.Join(names, " "))
 	}
-	return true, []config.Command{{Command: cmdStr, RequireRoot: cmd.RequireRoot}}, nil
+	cmd.Command = cmdStr
+	return true, []config.Command{cmd}, nil
 }
 
 func expandCommandForName(cmd config.Command, name string) (config.Command, error) {
@@ -61,3 +63,72 @@ func expandCommandForName(cmd config.Command, name string) (config.Command, erro
 	}
 	return expanded[0], nil
 }
+
+// expandVersionPlaceholder replaces {version} in a command that was already
+// expanded for a single package.
+func expandVersionPlaceholder(cmd config.Command, version string) config.Command {
+	cmd.Command = config.ReplaceRef(cmd.Command, placeholderVersion, version)
+	return cmd
+}
+
+// expandConfigVars expands vars and built-in variables such as {home} and
+// {bin_dir} in every command, its cwd and env values, and asset_pattern of
+// cfg. {name} is the name of the package or source the command belongs to.
+// Run-time placeholders such as {package} are left for expandCommandForNames.
+func expandConfigVars(cfg config.Config) config.Config {
+	vars, err := config.ResolveVars(cfg)
+	if err != nil {
+		logging.Debug(err.Error())
+		vars = config.BuiltinVars(cfg.Dir)
+	}
+	withName := func(name string) map[string]string {
+		out := make(map[string]string, len(vars)+1)
+		for k, v := range vars {
+			out[k] = v
+		}
+		out[config.VarName] = name
+		return out
+	}
+	// The second pass fills {name} in values of vars that refer to it.
+	expandString := func(s string, vars map[string]string) string {
+		return config.ExpandVarRefs(config.ExpandVarRefs(s, vars), vars)
+	}
+	expand := func(c *config.Command, vars map[string]string) {
+		c.Command = expandString(c.Command, vars)
+		c.Cwd = expandString(c.Cwd, vars)
+		if c.Env != nil {
+			env := make(map[string]string, len(c.Env))
+			for k, v := range c.Env {
+				env[k] = expandString(v, vars)
+			}
+			c.Env = env
+		}
+	}
+
+	cfg.Sources = append([]config.Source{}, cfg.Sources...)
+	for i := range cfg.Sources {
+		s := &cfg.Sources[i]
+		v := withName(s.Name)
+		for _, c := range []*config.Command{&s.Install, &s.Remove, &s.Update, &s.Search, &s.PreUpdate, &s.GetInstalledVersion, &s.GetLatestVersion, &s.ListInstalled} {
+			expand(c, v)
+		}
+	}
+	cfg.CustomPackages = append([]config.CustomPackage{}, cfg.CustomPackages...)
+	for i := range cfg.CustomPackages {
+		cp := &cfg.CustomPackages[i]
+		v := withName(cp.Name)
+		for _, c := range []*config.Command{&cp.GetInstalledVersion, &cp.GetLatestVersion, &cp.Install, &cp.Update, &cp.Remove} {
+			expand(c, v)
+		}
+	}
+	cfg.GithubReleasePackages = append([]config.GithubReleasePackage{}, cfg.GithubReleasePackages...)
+	for i := range cfg.GithubReleasePackages {
+		gp := &cfg.GithubReleasePackages[i]
+		v := withName(gp.Name)
+		for _, c := range []*config.Command{&gp.GetInstalledVersion, &gp.PostInstall, &gp.Remove} {
+			expand(c, v)
+		}
+		gp.AssetPattern = config.UnescapeRefs(expandString(gp.AssetPattern, v))
+	}
+	return cfg
+}
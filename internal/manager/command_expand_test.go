@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"runtime"
 	"strings"
 	"testing"
 
@@ -58,6 +59,17 @@ func TestExpandCommandForNames_GroupNoPlaceholder(t *testing.T) {
 	}
 }
 
+func TestExpandCommandForNames_EscapedPlaceholder(t *testing.T) {
+	cmd := config.Command{Command: "jq '{{package}}' && do {package_list}"}
+	group, expanded, err := expandCommandForNames(cmd, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !group || len(expanded) != 1 || expanded[0].Command != "jq '{{package}}' && do a b" {
+		t.Fatalf("unexpected expanded: %v %#v", group, expanded)
+	}
+}
+
 func TestExpandCommandForNames_BothPlaceholders_Error(t *testing.T) {
 	cmd := config.Command{Command: "do {package} {package_list}"}
 	_, _, err := expandCommandForNames(cmd, []string{"a"})
@@ -79,3 +91,47 @@ func TestExpandCommandForName_UsesSingleName(t *testing.T) {
 		t.Fatalf("unexpected command: %q", expanded.Command)
 	}
 }
+
+func TestExpandConfigVars(t *testing.T) {
+	cfg := config.Config{
+		Dir:  "/cfg",
+		Vars: map[string]string{"prefix": "/opt/{name}"},
+		Sources: []config.Source{{
+			Name:    "apt",
+			Install: config.Command{Command: "apt-get install -y {package} # {name}", RequireRoot: true},
+		}},
+		CustomPackages: []config.CustomPackage{{
+			Name:    "tool",
+			Install: config.Command{Command: "cp tool {bin_dir}/{name} && echo ${HOME} {config_dir}", Cwd: "{home}/src", Env: map[string]string{"PREFIX": "{prefix}"}},
+		}},
+		GithubReleasePackages: []config.GithubReleasePackage{{
+			Name:         "gh",
+			AssetPattern: "gh_{os}_{arch}",
+			PostInstall:  config.Command{Command: "tar -C {prefix} -xf $asset_path"},
+		}},
+	}
+	out := expandConfigVars(cfg)
+	home := config.BuiltinVars("")["home"]
+
+	if got := out.Sources[0].Install; got.Command != "apt-get install -y {package} # apt" || !got.RequireRoot {
+		t.Fatalf("source install = %+v", got)
+	}
+	if got := out.CustomPackages[0].Install.Command; got != "cp tool "+home+"/.local/bin/tool && echo ${HOME} /cfg" {
+		t.Fatalf("custom install = %q", got)
+	}
+	if got := out.CustomPackages[0].Install; got.Cwd != home+"/src" || got.Env["PREFIX"] != "/opt/tool" {
+		t.Fatalf("custom install cwd/env = %q %v", got.Cwd, got.Env)
+	}
+	if got := out.GithubReleasePackages[0].AssetPattern; got != "gh_"+runtime.GOOS+"_"+runtime.GOARCH {
+		t.Fatalf("asset_pattern = %q", got)
+	}
+	if got := out.GithubReleasePackages[0].PostInstall.Command; got != "tar -C /opt/gh -xf $asset_path" {
+		t.Fatalf("post_install = %q", got)
+	}
+	if cfg.CustomPackages[0].Install.Command != "cp tool {bin_dir}/{name} && echo ${HOME} {config_dir}" {
+		t.Fatal("input config was modified")
+	}
+	if cfg.CustomPackages[0].Install.Env["PREFIX"] != "{prefix}" {
+		t.Fatal("input env was modified")
+	}
+}
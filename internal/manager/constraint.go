@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/version"
+)
+
+// SatisfiesConstraint reports whether version v is allowed by constraint under
+// the named version scheme. An empty constraint allows every version and a
+// malformed one allows none.
+func SatisfiesConstraint(scheme, constraint, v string) bool {
+	if strings.TrimSpace(constraint) == "" {
+		return true
+	}
+	sc, err := version.ParseScheme(scheme)
+	if err != nil {
+		sc = version.Numeric
+	}
+	c, err := version.ParseConstraint(constraint, sc)
+	if err != nil {
+		return false
+	}
+	return c.Allows(v)
+}
+
+// VersionConstraint returns the version field configured for the package.
+func (m *Manager) VersionConstraint(k PackageKey) string {
+	switch k.Kind {
+	case "custom":
+		return m.customByName(k.Name).Version
+	case "github":
+		return m.githubByName(k.Name).Version
+	}
+	return m.pkgByName(k.Name).Version
+}
+
+func (m *Manager) constraintFor(name string) (version.Constraint, bool, error) {
+	k, err := m.KeyForName(name)
+	if err != nil {
+		return version.Constraint{}, false, nil
+	}
+	s := strings.TrimSpace(m.VersionConstraint(k))
+	if s == "" {
+		return version.Constraint{}, false, nil
+	}
+	c, err := version.ParseConstraint(s, m.schemeFor(name))
+	if err != nil {
+		return version.Constraint{}, false, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, true, nil
+}
+
+// constrain narrows the latest version reported for a package to its version
+// field: an exact pin always wins, and a range only accepts a matching latest
+// version. An empty result means no allowed version is available.
+func (m *Manager) constrain(name, latest string) (string, error) {
+	c, ok, err := m.constraintFor(name)
+	if err != nil || !ok {
+		return latest, err
+	}
+	if v, exact := c.Exact(); exact {
+		return v, nil
+	}
+	if latest != "" && c.Allows(latest) {
+		return latest, nil
+	}
+	return "", nil
+}
+
+// customLatestVersion returns the version a custom package should move to:
+// the locked version, or its get_latest_version output narrowed by the version
+// field. An empty result means no allowed version is known.
+func (m *Manager) customLatestVersion(cp config.CustomPackage) (string, error) {
+	if e, ok, err := m.lockedEntry(cp.Name); err != nil {
+		return "", err
+	} else if ok {
+		return e.Version, nil
+	}
+	latest := ""
+	if cp.GetLatestVersion.Command != "" {
+		var err error
+		if latest, err = m.readVersion(cp.Name, "get_latest_version", cp.GetLatestVersion); err != nil {
+			return "", err
+		}
+	}
+	return m.constrain(cp.Name, latest)
+}
+
+// sourceCandidate returns the version a source package would be installed at
+// without a lockfile: its exact pin, or the source candidate when the version
+// field allows it.
+func (m *Manager) sourceCandidate(name string) (string, error) {
+	c, ok, err := m.constraintFor(name)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		if v, exact := c.Exact(); exact {
+			return v, nil
+		}
+	}
+	p := m.pkgByName(name)
+	latest, err := m.sourceLatestVersion(m.sourceByName(p.Source), name)
+	if err != nil {
+		return "", err
+	}
+	v, err := m.constrain(name, latest)
+	if err != nil {
+		return "", err
+	}
+	if v == "" {
+		return "", fmt.Errorf("candidate %q of %s does not satisfy version %q", latest, name, p.Version)
+	}
+	return v, nil
+}
+
+// expandSourceVersion fills {version} in an already expanded source command
+// with the locked version, the exact pin or the allowed candidate of name.
+func (m *Manager) expandSourceVersion(cmd config.Command, name string) (config.Command, error) {
+	if !config.HasRef(cmd.Command, placeholderVersion) {
+		return cmd, nil
+	}
+	v, ok := m.lockedVersion(name)
+	if !ok {
+		var err error
+		if v, err = m.sourceCandidate(name); err != nil {
+			return config.Command{}, err
+		}
+	}
+	return expandVersionPlaceholder(cmd, v), nil
+}
@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	ghapi "github.com/the-gopak/gopak-cli/internal/github"
+)
+
+func TestSatisfiesConstraint(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "1.0.0", true},
+		{"0.9.4", "0.9.4", true},
+		{"0.9.4", "0.9.5", false},
+		{"==1.2", "1.2.0", true},
+		{">=1.2 <2", "1.9.9", true},
+		{">=1.2, <2", "2.0.0", false},
+		{">= 1.2", "1.1", false},
+		{"!=1.5", "1.5.0", false},
+		{"~1.4", "1.4.9", true},
+		{"~1.4", "1.5.0", false},
+		{"~1", "1.9", true},
+		{"^1.2", "1.9.0", true},
+		{"^1.2", "2.0.0", false},
+		{"^0.3", "0.3.7", true},
+		{"^0.3", "0.4.0", false},
+		{">=v1.2", "1.3.0", true},
+		{">=1", "", false},
+		{">=2.x.y garbage !!", "3.0.0", false},
+	}
+	for _, c := range cases {
+		if got := SatisfiesConstraint("", c.constraint, c.version); got != c.want {
+			t.Errorf("SatisfiesConstraint(%q, %q) = %v, want %v", c.constraint, c.version, got, c.want)
+		}
+	}
+}
+
+func TestSatisfiesConstraint_Scheme(t *testing.T) {
+	if SatisfiesConstraint("numeric", "<1.0.0", "1.0.0-rc.1") {
+		t.Fatal("numeric scheme ignores the prerelease suffix")
+	}
+	if !SatisfiesConstraint("semver", "<1.0.0", "1.0.0-rc.1") {
+		t.Fatal("semver prerelease sorts before the release")
+	}
+	if !SatisfiesConstraint("debian", ">=1:2.0", "1:2.43.0-1ubuntu7") {
+		t.Fatal("debian epoch must be honoured")
+	}
+}
+
+func TestGithubRelease_PicksNewestMatching(t *testing.T) {
+	m := New(config.Config{GithubReleasePackages: []config.GithubReleasePackage{{
+		Name: "tool", Repo: "org/tool", AssetPattern: "tool-*", Version: "<2",
+	}}})
+	gh := newFakeGithub()
+	gh.releases["v1.5.0"] = &ghapi.Release{TagName: "v1.5.0"}
+	gh.releases["v1.9.0-rc1"] = &ghapi.Release{TagName: "v1.9.0-rc1", Prerelease: true}
+	m.ghClient = gh
+	rel, err := m.githubRelease(m.githubByName("tool"))
+	if err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if rel.TagName != "v1.5.0" {
+		t.Fatalf("picked %s, want v1.5.0", rel.TagName)
+	}
+	if v := m.GetVersionAvailable(PackageKey{Source: "github", Name: "tool", Kind: "github"}); v != "v1.5.0" {
+		t.Fatalf("available = %q", v)
+	}
+}
+
+func TestCustomPackage_ExactPinPassedToInstall(t *testing.T) {
+	m := New(config.Config{CustomPackages: []config.CustomPackage{{
+		Name:             "mytool",
+		Version:          "0.9.4",
+		GetLatestVersion: config.Command{Command: "echo 1.0.0"},
+		Install:          config.Command{Command: "true"},
+	}}})
+	run := &mockRunner{}
+	if err := m.executeCustomWithRunner(m.customByName("mytool"), OpInstall, run); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if len(run.cmds) != 1 || !strings.Contains(run.cmds[0], `latest_version="0.9.4"`) {
+		t.Fatalf("pinned version not passed: %v", run.cmds)
+	}
+	if v := m.GetVersionAvailable(PackageKey{Source: "custom", Name: "mytool", Kind: "custom"}); v != "0.9.4" {
+		t.Fatalf("available = %q", v)
+	}
+}
+
+func TestCustomPackage_RangeHidesDisallowedUpdate(t *testing.T) {
+	m := New(config.Config{CustomPackages: []config.CustomPackage{{
+		Name:                "mytool",
+		Version:             "<2",
+		GetInstalledVersion: config.Command{Command: "echo 1.0.0"},
+		GetLatestVersion:    config.Command{Command: "echo 2.1.0"},
+		Update:              config.Command{Command: "true"},
+	}}})
+	run := &mockRunner{}
+	if err := m.executeCustomWithRunner(m.customByName("mytool"), OpUpdate, run); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if len(run.calls) != 0 {
+		t.Fatalf("disallowed update ran: %v", run.calls)
+	}
+}
+
+func TestSourceInstall_ExpandsVersion(t *testing.T) {
+	cfg := config.Config{
+		Sources: []config.Source{{
+			Name:             "apt",
+			Install:          config.Command{Command: "apt install -y {package}={version}"},
+			GetLatestVersion: config.Command{Command: "echo 2.45.0"},
+		}},
+		Packages: []config.Package{{Name: "git", Source: "apt", Version: "1:2.43.0"}},
+	}
+	m := New(cfg)
+	run := &mockRunner{}
+	keys := []PackageKey{{Source: "apt", Name: "git", Kind: "source"}}
+	if err := m.InstallSelected(keys, run, nil); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if len(run.cmds) != 1 || run.cmds[0] != "apt install -y git=1:2.43.0" {
+		t.Fatalf("unexpected command: %v", run.cmds)
+	}
+}
+
+func TestSourceInstall_RejectsDisallowedCandidate(t *testing.T) {
+	cfg := config.Config{
+		Sources: []config.Source{{
+			Name:             "apt",
+			Install:          config.Command{Command: "apt install -y {package}"},
+			GetLatestVersion: config.Command{Command: "echo 3.0.0"},
+		}},
+		Packages: []config.Package{{Name: "git", Source: "apt", Version: "<3"}},
+	}
+	m := New(cfg)
+	var failed string
+	keys := []PackageKey{{Source: "apt", Name: "git", Kind: "source"}}
+	_ = m.InstallSelected(keys, &mockRunner{}, func(k PackageKey, ok bool, msg string) {
+		if !ok {
+			failed = msg
+		}
+	})
+	if !strings.Contains(failed, "does not satisfy") {
+		t.Fatalf("expected constraint failure, got %q", failed)
+	}
+}
@@ -1,6 +1,14 @@
 package manager
 
-func topoOrder(nodes map[string][]string) ([]string, bool) {
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// topoOrder orders nodes so that every node follows its dependencies. A cycle
+// is reported with its path, e.g. "dependency cycle: a -> b -> a".
+func topoOrder(nodes map[string][]string) ([]string, error) {
 	indeg := map[string]int{}
 	out := map[string][]string{}
 	for n, deps := range nodes {
@@ -33,7 +41,119 @@ func topoOrder(nodes map[string][]string) ([]string, bool) {
 		}
 	}
 	if len(order) != len(indeg) {
-		return nil, false
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(findCycle(nodes, indeg), " -> "))
+	}
+	return order, nil
+}
+
+// findCycle walks the nodes left over by topoOrder, all of which still wait
+// on another leftover node, until one repeats.
+func findCycle(nodes map[string][]string, indeg map[string]int) []string {
+	var left []string
+	for n, d := range indeg {
+		if d > 0 {
+			left = append(left, n)
+		}
+	}
+	sort.Strings(left)
+	pos := map[string]int{}
+	path := []string{}
+	n := left[0]
+	for {
+		if i, ok := pos[n]; ok {
+			return append(path[i:], n)
+		}
+		pos[n] = len(path)
+		path = append(path, n)
+		deps := append([]string{}, nodes[n]...)
+		sort.Strings(deps)
+		for _, d := range deps {
+			if indeg[d] > 0 {
+				n = d
+				break
+			}
+		}
+	}
+}
+
+// dependencyGraph maps every configured package to its depends_on list.
+func (m *Manager) dependencyGraph() map[string][]string {
+	nodes := map[string][]string{}
+	for _, p := range m.cfg.Packages {
+		nodes[p.Name] = append([]string{}, p.DependsOn...)
+	}
+	for _, c := range m.cfg.CustomPackages {
+		nodes[c.Name] = append([]string{}, c.DependsOn...)
+	}
+	for _, g := range m.cfg.GithubReleasePackages {
+		nodes[g.Name] = append([]string{}, g.DependsOn...)
+	}
+	return nodes
+}
+
+// selectedDeps returns, for each selected package, the selected packages it
+// depends on. Dependencies are followed through packages that are not
+// selected, so a -> x -> b still orders b before a.
+func selectedDeps(nodes map[string][]string, keys []PackageKey) map[string][]string {
+	selected := map[string]bool{}
+	for _, k := range keys {
+		selected[k.Name] = true
+	}
+	out := map[string][]string{}
+	for _, k := range keys {
+		seen := map[string]bool{k.Name: true}
+		var visit func(n string)
+		visit = func(n string) {
+			for _, d := range nodes[n] {
+				if seen[d] {
+					continue
+				}
+				seen[d] = true
+				if selected[d] {
+					out[k.Name] = append(out[k.Name], d)
+					continue
+				}
+				visit(d)
+			}
+		}
+		visit(k.Name)
+		if out[k.Name] == nil {
+			out[k.Name] = []string{}
+		}
+	}
+	return out
+}
+
+// dependencyLevels splits keys into levels that can run in order: every
+// package comes after the selected packages it depends on, and packages in the
+// same level do not depend on each other. Keys keep their relative order.
+func dependencyLevels(nodes map[string][]string, keys []PackageKey) ([][]PackageKey, map[string][]string, error) {
+	deps := selectedDeps(nodes, keys)
+	if _, err := topoOrder(deps); err != nil {
+		return nil, nil, err
+	}
+	level := map[string]int{}
+	var depth func(n string) int
+	depth = func(n string) int {
+		if l, ok := level[n]; ok {
+			return l
+		}
+		l := 0
+		for _, d := range deps[n] {
+			if dl := depth(d) + 1; dl > l {
+				l = dl
+			}
+		}
+		level[n] = l
+		return l
+	}
+	var levels [][]PackageKey
+	for _, k := range keys {
+		l := depth(k.Name)
+		for len(levels) <= l {
+			levels = append(levels, nil)
+		}
+		levels[l] = append(levels[l], k)
 	}
-	return order, true
+	return levels, deps, nil
 }
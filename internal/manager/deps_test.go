@@ -1,6 +1,9 @@
 package manager
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestTopoOrder(t *testing.T) {
 	nodes := map[string][]string{
@@ -8,9 +11,9 @@ func TestTopoOrder(t *testing.T) {
 		"b": {"a"},
 		"c": {"a", "b"},
 	}
-	ord, ok := topoOrder(nodes)
-	if !ok {
-		t.Fatalf("cycle detected")
+	ord, err := topoOrder(nodes)
+	if err != nil {
+		t.Fatalf("cycle detected: %v", err)
 	}
 	pos := map[string]int{}
 	for i, n := range ord {
@@ -24,10 +27,55 @@ func TestTopoOrder(t *testing.T) {
 func TestTopoOrderCycle(t *testing.T) {
 	nodes := map[string][]string{
 		"a": {"b"},
-		"b": {"a"},
+		"b": {"c"},
+		"c": {"a"},
+		"d": {"a"},
 	}
-	_, ok := topoOrder(nodes)
-	if ok {
+	_, err := topoOrder(nodes)
+	if err == nil {
 		t.Fatalf("expected cycle")
 	}
+	if err.Error() != "dependency cycle: a -> b -> c -> a" {
+		t.Fatalf("cycle path not reported: %v", err)
+	}
+}
+
+func TestDependencyLevels(t *testing.T) {
+	nodes := map[string][]string{
+		"git":    {},
+		"neovim": {"git"},
+		"tool":   {"helper"},
+		"helper": {"git"},
+		"fish":   {},
+	}
+	keys := []PackageKey{
+		{Source: "custom", Name: "tool", Kind: "custom"},
+		{Source: "apt", Name: "neovim", Kind: "source"},
+		{Source: "apt", Name: "git", Kind: "source"},
+		{Source: "apt", Name: "fish", Kind: "source"},
+	}
+	levels, _, err := dependencyLevels(nodes, keys)
+	if err != nil {
+		t.Fatalf("levels: %v", err)
+	}
+	var names [][]string
+	for _, l := range levels {
+		var ns []string
+		for _, k := range l {
+			ns = append(ns, k.Name)
+		}
+		names = append(names, ns)
+	}
+	want := [][]string{{"git", "fish"}, {"tool", "neovim"}}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("levels = %v, want %v", names, want)
+	}
+}
+
+func TestDependencyLevelsCycle(t *testing.T) {
+	nodes := map[string][]string{"a": {"b"}, "b": {"a"}}
+	keys := []PackageKey{{Source: "custom", Name: "a", Kind: "custom"}, {Source: "custom", Name: "b", Kind: "custom"}}
+	if _, _, err := dependencyLevels(nodes, keys); err == nil {
+		t.Fatal("expected cycle error")
+	}
 }
@@ -5,22 +5,15 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
 )
 
 type execCache struct {
 	Entries map[string]time.Time `json:"entries"`
 }
 
-func execCacheDir() string {
-	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
-		return filepath.Join(xdg, "gopak")
-	}
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(".cache", "gopak")
-	}
-	return filepath.Join(home, ".cache", "gopak")
-}
+func execCacheDir() string { return config.CacheDir() }
 
 func execCachePath() string {
 	return filepath.Join(execCacheDir(), "exec-cache.json")
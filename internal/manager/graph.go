@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"errors"
+	"sort"
+)
+
+// GraphNode is a package in the dependency graph. Source and Kind are empty
+// for names that appear in depends_on but are not configured.
+type GraphNode struct {
+	Name      string   `json:"name"`
+	Source    string   `json:"source"`
+	Kind      string   `json:"kind"`
+	DependsOn []string `json:"depends_on"`
+}
+
+// Graph returns the dependency graph sorted by name. With a root only the
+// root and everything it depends on, directly or not, are included.
+func (m *Manager) Graph(root string) ([]GraphNode, error) {
+	nodes := m.dependencyGraph()
+	names := map[string]bool{}
+	if root != "" {
+		if _, ok := nodes[root]; !ok {
+			return nil, errors.New("unknown package: " + root)
+		}
+		var visit func(n string)
+		visit = func(n string) {
+			if names[n] {
+				return
+			}
+			names[n] = true
+			for _, d := range nodes[n] {
+				visit(d)
+			}
+		}
+		visit(root)
+	} else {
+		for n, deps := range nodes {
+			names[n] = true
+			for _, d := range deps {
+				names[d] = true
+			}
+		}
+	}
+	out := make([]GraphNode, 0, len(names))
+	for n := range names {
+		node := GraphNode{Name: n, DependsOn: append([]string{}, nodes[n]...)}
+		sort.Strings(node.DependsOn)
+		if k, err := m.KeyForName(n); err == nil {
+			node.Source = k.Source
+			node.Kind = k.Kind
+		}
+		out = append(out, node)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Why returns every chain of packages that leads to name, each starting at a
+// package nothing depends on and ending with name. Chains are sorted.
+func (m *Manager) Why(name string) ([][]string, error) {
+	nodes := m.dependencyGraph()
+	if _, err := m.KeyForName(name); err != nil {
+		return nil, err
+	}
+	rdeps := map[string][]string{}
+	for n, deps := range nodes {
+		for _, d := range deps {
+			rdeps[d] = append(rdeps[d], n)
+		}
+	}
+	var chains [][]string
+	onPath := map[string]bool{}
+	var walk func(n string, path []string)
+	walk = func(n string, path []string) {
+		path = append([]string{n}, path...)
+		parents := 0
+		onPath[n] = true
+		for _, p := range rdeps[n] {
+			if onPath[p] {
+				continue
+			}
+			parents++
+			walk(p, path)
+		}
+		onPath[n] = false
+		if parents == 0 && len(path) > 1 {
+			chains = append(chains, path)
+		}
+	}
+	walk(name, nil)
+	sort.Slice(chains, func(i, j int) bool {
+		a, b := chains[i], chains[j]
+		for x := 0; x < len(a) && x < len(b); x++ {
+			if a[x] != b[x] {
+				return a[x] < b[x]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return chains, nil
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+)
+
+func graphConfig() config.Config {
+	return config.Config{
+		Sources:  []config.Source{{Name: "apt"}},
+		Packages: []config.Package{{Name: "git", Source: "apt"}, {Name: "curl", Source: "apt"}},
+		CustomPackages: []config.CustomPackage{
+			{Name: "helper", DependsOn: []string{"git"}},
+			{Name: "tool", DependsOn: []string{"helper", "curl"}},
+		},
+		GithubReleasePackages: []config.GithubReleasePackage{{Name: "lazygit", DependsOn: []string{"git"}}},
+	}
+}
+
+func TestGraph(t *testing.T) {
+	m := New(graphConfig())
+	nodes, err := m.Graph("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	if want := []string{"curl", "git", "helper", "lazygit", "tool"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("nodes = %v, want %v", names, want)
+	}
+	if nodes[3].Kind != "github" || nodes[3].Source != "github" {
+		t.Fatalf("lazygit node = %+v", nodes[3])
+	}
+	if want := []string{"curl", "helper"}; !reflect.DeepEqual(nodes[4].DependsOn, want) {
+		t.Fatalf("tool deps = %v, want %v", nodes[4].DependsOn, want)
+	}
+
+	sub, err := m.Graph("helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sub) != 2 || sub[0].Name != "git" || sub[1].Name != "helper" {
+		t.Fatalf("subgraph = %+v", sub)
+	}
+	if _, err := m.Graph("nope"); err == nil {
+		t.Fatal("expected error for unknown package")
+	}
+}
+
+func TestWhy(t *testing.T) {
+	m := New(graphConfig())
+	chains, err := m.Why("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"lazygit", "git"}, {"tool", "helper", "git"}}
+	if !reflect.DeepEqual(chains, want) {
+		t.Fatalf("chains = %v, want %v", chains, want)
+	}
+	chains, err = m.Why("tool")
+	if err != nil || len(chains) != 0 {
+		t.Fatalf("tool: %v, %v", chains, err)
+	}
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/executil"
+	"github.com/the-gopak/gopak-cli/internal/logging"
+)
+
+// Untracked lists packages reported by the list_installed command of a source
+// that the configuration does not track yet. With an empty source every source
+// that defines list_installed is queried, and sources whose command fails
+// (usually because the package manager is not present) are skipped.
+func (m *Manager) Untracked(source string) ([]config.Package, error) {
+	var sources []config.Source
+	if source != "" {
+		s := m.sourceByName(source)
+		if s.Name == "" {
+			return nil, fmt.Errorf("unknown source: %s", source)
+		}
+		if s.ListInstalled.Command == "" {
+			return nil, fmt.Errorf("missing list_installed script for source: %s", source)
+		}
+		sources = append(sources, s)
+	} else {
+		for _, s := range m.cfg.Sources {
+			if s.ListInstalled.Command != "" {
+				sources = append(sources, s)
+			}
+		}
+		sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	}
+
+	// Packages known to their source under another name are tracked too.
+	aliases := map[string]bool{}
+	for name, srcName := range m.sourceNames {
+		aliases[m.pkgByName(name).Source+"/"+srcName] = true
+	}
+	seen := map[string]struct{}{}
+	var out []config.Package
+	for _, s := range sources {
+		logging.Debug(fmt.Sprintf("%s [list_installed]: %s", s.Name, s.ListInstalled.Command))
+		res := executil.RunShell(s.ListInstalled)
+		if res.Code != 0 {
+			if source != "" {
+				return nil, fmt.Errorf("command failed for %s [list_installed]: exit %d\n%s", s.Name, res.Code, res.Stderr)
+			}
+			logging.Debug(fmt.Sprintf("%s [list_installed failed]: exit=%d", s.Name, res.Code))
+			continue
+		}
+		var names []string
+		for _, line := range strings.Split(res.Stdout, "\n") {
+			name := strings.TrimSpace(line)
+			if name == "" {
+				continue
+			}
+			if _, err := m.KeyForName(name); err == nil || aliases[s.Name+"/"+name] {
+				continue
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			out = append(out, config.Package{Name: name, Source: s.Name})
+		}
+	}
+	return out, nil
+}
+
+// DetectSources returns the names of the sources that work on this machine,
+// sorted: those whose list_installed program is on PATH and whose
+// list_installed command succeeds. Sources without list_installed are skipped.
+func (m *Manager) DetectSources() []string {
+	var out []string
+	for _, s := range m.cfg.Sources {
+		fields := strings.Fields(s.ListInstalled.Command)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			logging.Debug(fmt.Sprintf("%s [detect]: %s not found", s.Name, fields[0]))
+			continue
+		}
+		if res := executil.RunShell(s.ListInstalled); res.Code != 0 {
+			logging.Debug(fmt.Sprintf("%s [detect]: list_installed exit=%d", s.Name, res.Code))
+			continue
+		}
+		out = append(out, s.Name)
+	}
+	sort.Strings(out)
+	return out
+}
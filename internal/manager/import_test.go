@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+)
+
+func TestUntracked_SkipsTrackedAndDuplicates(t *testing.T) {
+	cfg := config.Config{
+		Sources: []config.Source{
+			{Name: "apt", ListInstalled: config.Command{Command: "printf 'git\\nhtop\\n\\nripgrep\\n'"}},
+			{Name: "snap", ListInstalled: config.Command{Command: "printf 'htop\\ncode\\n'"}},
+			{Name: "broken", ListInstalled: config.Command{Command: "exit 127"}},
+			{Name: "none"},
+		},
+		Packages:       []config.Package{{Name: "git", Source: "apt"}},
+		CustomPackages: []config.CustomPackage{{Name: "ripgrep"}},
+	}
+	m := New(cfg)
+	got, err := m.Untracked("")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := []config.Package{
+		{Name: "htop", Source: "apt"},
+		{Name: "code", Source: "snap"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestUntracked_SingleSourceErrors(t *testing.T) {
+	cfg := config.Config{Sources: []config.Source{
+		{Name: "apt"},
+		{Name: "broken", ListInstalled: config.Command{Command: "exit 127"}},
+	}}
+	m := New(cfg)
+	for _, src := range []string{"missing", "apt", "broken"} {
+		if _, err := m.Untracked(src); err == nil {
+			t.Fatalf("expected error for source %q", src)
+		}
+	}
+}
+
+func TestDetectSources(t *testing.T) {
+	cfg := config.Config{Sources: []config.Source{
+		{Name: "snap", ListInstalled: config.Command{Command: "echo code"}},
+		{Name: "apt", ListInstalled: config.Command{Command: "true"}},
+		{Name: "missing", ListInstalled: config.Command{Command: "gopak-no-such-manager list"}},
+		{Name: "failing", ListInstalled: config.Command{Command: "false"}},
+		{Name: "npx"},
+	}}
+	got := New(cfg).DetectSources()
+	if want := []string{"apt", "snap"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
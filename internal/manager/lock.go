@@ -0,0 +1,280 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	ghapi "github.com/the-gopak/gopak-cli/internal/github"
+	"github.com/the-gopak/gopak-cli/internal/lockfile"
+	"github.com/the-gopak/gopak-cli/internal/logging"
+	"github.com/the-gopak/gopak-cli/internal/state"
+)
+
+// UseLock makes installs and updates follow the versions pinned in l instead
+// of the newest available ones.
+func (m *Manager) UseLock(l *lockfile.Lockfile) { m.lock = l }
+
+// lockedEntry returns the pinned entry for name. It reports ok=false when no
+// lockfile is in use and an error when the package is missing from it.
+func (m *Manager) lockedEntry(name string) (lockfile.Entry, bool, error) {
+	if m.lock == nil {
+		return lockfile.Entry{}, false, nil
+	}
+	e, ok := m.lock.Get(name)
+	if !ok {
+		return lockfile.Entry{}, false, fmt.Errorf("%s is not in %s; run gopak lock", name, lockfile.FileName)
+	}
+	return e, true, nil
+}
+
+// lockedVersion returns the pinned version for name when a lockfile is in use.
+func (m *Manager) lockedVersion(name string) (string, bool) {
+	e, ok, err := m.lockedEntry(name)
+	if err != nil || !ok {
+		return "", false
+	}
+	return e.Version, true
+}
+
+// checkSourceCandidate verifies that running cmd for a source package would
+// install an acceptable version. Package managers install their current
+// candidate unless the command pins {version}, so without that placeholder the
+// candidate must equal the locked version and satisfy the version field.
+func (m *Manager) checkSourceCandidate(name string, cmd config.Command) error {
+	if config.HasRef(cmd.Command, placeholderVersion) {
+		return nil
+	}
+	e, locked, err := m.lockedEntry(name)
+	if err != nil {
+		return err
+	}
+	c, constrained, err := m.constraintFor(name)
+	if err != nil {
+		return err
+	}
+	if !locked && !constrained {
+		return nil
+	}
+	p := m.pkgByName(name)
+	s := m.sourceByName(p.Source)
+	candidate, err := m.sourceLatestVersion(s, name)
+	if err != nil {
+		return err
+	}
+	if locked && candidate != e.Version {
+		return fmt.Errorf("locked version %s of %s is not available from %s (candidate %s)", e.Version, name, s.Name, candidate)
+	}
+	if constrained && !c.Allows(candidate) {
+		return fmt.Errorf("candidate %s of %s from %s does not satisfy version %q; use {version} in the source command to pin it", candidate, name, s.Name, m.pkgByName(name).Version)
+	}
+	return nil
+}
+
+func (m *Manager) sourceLatestVersion(s config.Source, name string) (string, error) {
+	if s.GetLatestVersion.Command == "" {
+		return "", fmt.Errorf("missing get_latest_version script for source: %s", s.Name)
+	}
+	m.ensurePreUpdate(s)
+	expanded, err := expandCommandForName(s.GetLatestVersion, m.sourcePackageName(name))
+	if err != nil {
+		return "", fmt.Errorf("invalid placeholders for source %s [get_latest_version]: %w", s.Name, err)
+	}
+	return m.readVersion(name, "get_latest_version", expanded)
+}
+
+// githubRelease returns the release to install for gp: the locked tag when a
+// lockfile is in use, the latest release otherwise.
+func (m *Manager) githubRelease(gp config.GithubReleasePackage) (*ghapi.Release, error) {
+	e, ok, err := m.lockedEntry(gp.Name)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return m.ghClient.GetReleaseByTag(gp.Repo, e.Tag)
+	}
+	return m.latestGithubRelease(gp)
+}
+
+// latestGithubRelease returns the newest release of gp allowed by its version
+// field. Without one it is the release GitHub marks as latest.
+func (m *Manager) latestGithubRelease(gp config.GithubReleasePackage) (*ghapi.Release, error) {
+	c, ok, err := m.constraintFor(gp.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return m.ghClient.GetLatestRelease(gp.Repo)
+	}
+	releases, err := m.ghClient.ListReleases(gp.Repo)
+	if err != nil {
+		return nil, err
+	}
+	var best *ghapi.Release
+	bestVersion := ""
+	for i := range releases {
+		r := &releases[i]
+		if r.Prerelease {
+			continue
+		}
+		v, err := m.releaseVersion(gp, r)
+		if err != nil || !c.Allows(v) {
+			continue
+		}
+		if best == nil || m.schemeFor(gp.Name).Compare(v, bestVersion) > 0 {
+			best, bestVersion = r, v
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no release of %s satisfies version %q", gp.Repo, gp.Version)
+	}
+	return best, nil
+}
+
+// downloadGithubAsset downloads the asset of rel selected by gp into dir.
+// With a lockfile in use the locked asset is chosen and its checksum verified.
+func (m *Manager) downloadGithubAsset(gp config.GithubReleasePackage, rel *ghapi.Release, dir string) (string, error) {
+	e, locked, err := m.lockedEntry(gp.Name)
+	if err != nil {
+		return "", err
+	}
+	pattern := gp.AssetPattern
+	if locked && e.Asset != "" {
+		pattern = e.Asset
+	}
+	asset, err := m.ghClient.FindAsset(rel, pattern)
+	if err != nil {
+		return "", err
+	}
+	path, err := m.ghClient.DownloadAsset(asset, dir)
+	if err != nil {
+		return "", err
+	}
+	if locked && e.SHA256 != "" {
+		sum, err := state.FileChecksum(path)
+		if err != nil {
+			return "", err
+		}
+		if sum != e.SHA256 {
+			return "", fmt.Errorf("checksum mismatch for %s asset %s: got %s, locked %s", gp.Name, asset.Name, sum, e.SHA256)
+		}
+	}
+	return path, nil
+}
+
+// resolveLockEntry determines the version that would be installed for name
+// right now, in the form recorded in the lockfile.
+func (m *Manager) resolveLockEntry(name string) (lockfile.Entry, error) {
+	k, err := m.KeyForName(name)
+	if err != nil {
+		return lockfile.Entry{}, err
+	}
+	switch k.Kind {
+	case "github":
+		gp := m.githubByName(name)
+		rel, err := m.latestGithubRelease(gp)
+		if err != nil {
+			return lockfile.Entry{}, err
+		}
+		asset, err := m.ghClient.FindAsset(rel, gp.AssetPattern)
+		if err != nil {
+			return lockfile.Entry{}, err
+		}
+		sum := asset.SHA256()
+		if sum == "" {
+			tmpDir, err := os.MkdirTemp("", "gopak-"+gp.Name+"-")
+			if err != nil {
+				return lockfile.Entry{}, err
+			}
+			defer os.RemoveAll(tmpDir)
+			path, err := m.ghClient.DownloadAsset(asset, tmpDir)
+			if err != nil {
+				return lockfile.Entry{}, err
+			}
+			if sum, err = state.FileChecksum(path); err != nil {
+				return lockfile.Entry{}, err
+			}
+		}
+		v, err := m.releaseVersion(gp, rel)
+		if err != nil {
+			return lockfile.Entry{}, err
+		}
+		return lockfile.Entry{Kind: k.Kind, Version: v, Tag: strings.TrimSpace(rel.TagName), Asset: asset.Name, URL: asset.BrowserDownloadURL, SHA256: sum}, nil
+	case "custom":
+		cp := m.customByName(name)
+		if cp.GetLatestVersion.Command == "" && strings.TrimSpace(cp.Version) == "" {
+			return lockfile.Entry{}, fmt.Errorf("missing get_latest_version script for custom package: %s", name)
+		}
+		v, err := m.customLatestVersion(cp)
+		if err != nil {
+			return lockfile.Entry{}, err
+		}
+		if v == "" {
+			return lockfile.Entry{}, fmt.Errorf("no latest version of %s satisfies its version field", name)
+		}
+		return lockfile.Entry{Kind: k.Kind, Version: v}, nil
+	}
+	v, err := m.sourceCandidate(name)
+	if err != nil {
+		return lockfile.Entry{}, err
+	}
+	return lockfile.Entry{Kind: k.Kind, Source: k.Source, Version: v}, nil
+}
+
+// UpdateLock resolves versions into l. Without refresh only packages missing
+// from the lockfile are resolved; with refresh the named packages (or all of
+// them when names is empty) are resolved again. Entries for packages that are
+// no longer configured are dropped. Resolution continues past failures, which
+// are returned together.
+func (m *Manager) UpdateLock(l *lockfile.Lockfile, names []string, refresh bool) error {
+	all := m.allNames()
+	for _, n := range names {
+		if _, err := m.KeyForName(n); err != nil {
+			return err
+		}
+	}
+	if len(names) == 0 {
+		names = all
+	}
+	for _, n := range l.Names() {
+		if _, err := m.KeyForName(n); err != nil {
+			l.Delete(n)
+			logging.Info("unlocked: " + n)
+		}
+	}
+	var errs []error
+	for _, n := range names {
+		if _, ok := l.Get(n); ok && !refresh {
+			continue
+		}
+		e, err := m.resolveLockEntry(n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n, err))
+			continue
+		}
+		l.Set(n, e)
+		logging.Success("locked: " + n + " " + e.Version)
+	}
+	if err := l.Save(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) allNames() []string {
+	var names []string
+	for _, p := range m.cfg.Packages {
+		names = append(names, p.Name)
+	}
+	for _, c := range m.cfg.CustomPackages {
+		names = append(names, c.Name)
+	}
+	for _, g := range m.cfg.GithubReleasePackages {
+		names = append(names, g.Name)
+	}
+	sort.Strings(names)
+	return names
+}
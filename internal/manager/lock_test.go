@@ -0,0 +1,181 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	ghapi "github.com/the-gopak/gopak-cli/internal/github"
+	"github.com/the-gopak/gopak-cli/internal/lockfile"
+	"github.com/the-gopak/gopak-cli/internal/state"
+)
+
+type fakeGithub struct {
+	releases map[string]*ghapi.Release
+	latest   string
+	content  string
+}
+
+func (f *fakeGithub) GetLatestRelease(repo string) (*ghapi.Release, error) {
+	return f.releases[f.latest], nil
+}
+
+func (f *fakeGithub) GetReleaseByTag(repo, tag string) (*ghapi.Release, error) {
+	return f.releases[tag], nil
+}
+
+func (f *fakeGithub) ListReleases(repo string) ([]ghapi.Release, error) {
+	var out []ghapi.Release
+	for _, r := range f.releases {
+		out = append(out, *r)
+	}
+	return out, nil
+}
+
+func (f *fakeGithub) FindAsset(release *ghapi.Release, pattern string) (*ghapi.Asset, error) {
+	return ghapi.NewClient().FindAsset(release, pattern)
+}
+
+func (f *fakeGithub) DownloadAsset(asset *ghapi.Asset, destDir string) (string, error) {
+	path := filepath.Join(destDir, asset.Name)
+	return path, os.WriteFile(path, []byte(f.content+asset.Name), 0o644)
+}
+
+func newFakeGithub() *fakeGithub {
+	return &fakeGithub{
+		latest: "v2.0.0",
+		releases: map[string]*ghapi.Release{
+			"v1.0.0": {TagName: "v1.0.0", Assets: []ghapi.Asset{{Name: "tool-1.0.0-linux.tar.gz", BrowserDownloadURL: "https://example.com/1"}}},
+			"v2.0.0": {TagName: "v2.0.0", Assets: []ghapi.Asset{{Name: "tool-2.0.0-linux.tar.gz", BrowserDownloadURL: "https://example.com/2"}}},
+		},
+	}
+}
+
+func TestUpdateLock_ResolvesEveryKind(t *testing.T) {
+	cfg := config.Config{
+		Sources:        []config.Source{{Name: "apt", GetLatestVersion: config.Command{Command: "echo 2.43.0-{package}"}}},
+		Packages:       []config.Package{{Name: "git", Source: "apt"}},
+		CustomPackages: []config.CustomPackage{{Name: "mytool", GetLatestVersion: config.Command{Command: "echo 0.9.4"}}},
+		GithubReleasePackages: []config.GithubReleasePackage{{
+			Name: "tool", Repo: "org/tool", AssetPattern: "tool-*-linux.tar.gz",
+		}},
+	}
+	m := New(cfg)
+	gh := newFakeGithub()
+	m.ghClient = gh
+	l, _ := lockfile.Load(t.TempDir())
+	l.Set("removed", lockfile.Entry{Kind: "custom", Version: "1"})
+	if err := m.UpdateLock(l, nil, false); err != nil {
+		t.Fatalf("UpdateLock: %v", err)
+	}
+	if e, _ := l.Get("git"); e.Version != "2.43.0-git" || e.Source != "apt" {
+		t.Fatalf("source entry: %#v", e)
+	}
+	if e, _ := l.Get("mytool"); e.Version != "0.9.4" {
+		t.Fatalf("custom entry: %#v", e)
+	}
+	e, _ := l.Get("tool")
+	if e.Tag != "v2.0.0" || e.Asset != "tool-2.0.0-linux.tar.gz" || e.URL != "https://example.com/2" || e.SHA256 == "" {
+		t.Fatalf("github entry: %#v", e)
+	}
+	if _, ok := l.Get("removed"); ok {
+		t.Fatal("entries for unconfigured packages must be dropped")
+	}
+	if !l.Exists() {
+		t.Fatal("lockfile must be saved")
+	}
+
+	gh.latest = "v1.0.0"
+	if err := m.UpdateLock(l, nil, false); err != nil {
+		t.Fatalf("UpdateLock: %v", err)
+	}
+	if e, _ := l.Get("tool"); e.Tag != "v2.0.0" {
+		t.Fatalf("locked entries must be kept without refresh: %#v", e)
+	}
+	if err := m.UpdateLock(l, []string{"tool"}, true); err != nil {
+		t.Fatalf("UpdateLock: %v", err)
+	}
+	if e, _ := l.Get("tool"); e.Tag != "v1.0.0" {
+		t.Fatalf("refresh must resolve again: %#v", e)
+	}
+}
+
+func TestInstallLocked_GithubUsesPinnedReleaseAndVerifiesChecksum(t *testing.T) {
+	cfg := config.Config{GithubReleasePackages: []config.GithubReleasePackage{{
+		Name: "tool", Repo: "org/tool", AssetPattern: "tool-*-linux.tar.gz",
+		PostInstall: config.Command{Command: "echo install"},
+	}}}
+	m := New(cfg)
+	gh := newFakeGithub()
+	m.ghClient = gh
+	dir := t.TempDir()
+	l, _ := lockfile.Load(dir)
+
+	sumFile := filepath.Join(dir, "expected")
+	os.WriteFile(sumFile, []byte(gh.content+"tool-1.0.0-linux.tar.gz"), 0o644)
+	sum, _ := state.FileChecksum(sumFile)
+	l.Set("tool", lockfile.Entry{Kind: "github", Version: "v1.0.0", Tag: "v1.0.0", Asset: "tool-1.0.0-linux.tar.gz", SHA256: sum})
+	m.UseLock(l)
+
+	run := &mockRunner{}
+	key := PackageKey{Source: "github", Name: "tool", Kind: "github"}
+	var failures []string
+	onDone := func(k PackageKey, ok bool, msg string) {
+		if !ok {
+			failures = append(failures, msg)
+		}
+	}
+	if err := m.InstallSelected([]PackageKey{key}, run, onDone); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(failures) != 0 || len(run.calls) != 1 {
+		t.Fatalf("locked install should succeed: failures=%v calls=%v", failures, run.calls)
+	}
+	if got := m.GetVersionAvailable(key); got != "v1.0.0" {
+		t.Fatalf("available version should be the locked one, got %q", got)
+	}
+
+	l.Set("tool", lockfile.Entry{Kind: "github", Version: "v1.0.0", Tag: "v1.0.0", Asset: "tool-1.0.0-linux.tar.gz", SHA256: "deadbeef"})
+	run = &mockRunner{}
+	if err := m.InstallSelected([]PackageKey{key}, run, onDone); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(failures) != 1 || !strings.Contains(failures[0], "checksum mismatch") || len(run.calls) != 0 {
+		t.Fatalf("checksum mismatch must fail before post_install: failures=%v calls=%v", failures, run.calls)
+	}
+}
+
+func TestInstallLocked_SourceRequiresLockedCandidate(t *testing.T) {
+	cfg := config.Config{
+		Sources: []config.Source{{
+			Name:             "apt",
+			Install:          config.Command{Command: "true"},
+			GetLatestVersion: config.Command{Command: "echo 2.0"},
+		}},
+		Packages: []config.Package{{Name: "git", Source: "apt"}, {Name: "vim", Source: "apt"}},
+	}
+	m := New(cfg)
+	l, _ := lockfile.Load(t.TempDir())
+	l.Set("git", lockfile.Entry{Kind: "source", Source: "apt", Version: "1.0"})
+	l.Set("vim", lockfile.Entry{Kind: "source", Source: "apt", Version: "2.0"})
+	m.UseLock(l)
+
+	if err := m.Install("git"); err == nil || !strings.Contains(err.Error(), "locked version 1.0") {
+		t.Fatalf("expected locked candidate error, got %v", err)
+	}
+	if err := m.Install("vim"); err != nil {
+		t.Fatalf("matching candidate should install: %v", err)
+	}
+}
+
+func TestInstallLocked_MissingEntryFails(t *testing.T) {
+	cfg := config.Config{CustomPackages: []config.CustomPackage{{Name: "tool", Install: config.Command{Command: "true"}}}}
+	m := New(cfg)
+	l, _ := lockfile.Load(t.TempDir())
+	m.UseLock(l)
+	if err := m.Install("tool"); err == nil || !strings.Contains(err.Error(), "gopak lock") {
+		t.Fatalf("expected missing lock entry error, got %v", err)
+	}
+}
@@ -7,24 +7,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/the-gopak/gopak-cli/internal/config"
 	"github.com/the-gopak/gopak-cli/internal/executil"
 	ghapi "github.com/the-gopak/gopak-cli/internal/github"
+	"github.com/the-gopak/gopak-cli/internal/lockfile"
 	"github.com/the-gopak/gopak-cli/internal/logging"
+	"github.com/the-gopak/gopak-cli/internal/state"
+	"github.com/the-gopak/gopak-cli/internal/version"
 )
 
 type githubClient interface {
 	GetLatestRelease(repo string) (*ghapi.Release, error)
+	GetReleaseByTag(repo, tag string) (*ghapi.Release, error)
+	ListReleases(repo string) ([]ghapi.Release, error)
 	FindAsset(release *ghapi.Release, pattern string) (*ghapi.Asset, error)
 	DownloadAsset(asset *ghapi.Asset, destDir string) (string, error)
 }
 
 type Manager struct {
+	// base is the loaded configuration; cfg is derived from it by load.
+	base          config.Config
 	cfg           config.Config
 	ghClient      githubClient
 	customByIdx   map[string]int
@@ -32,6 +37,18 @@ type Manager struct {
 	pkgByIdx      map[string]int
 	sourceByIdx   map[string]int
 	preUpdateOnce sync.Map
+	state         *state.Manager
+	lock          *lockfile.Lockfile
+	// profile holds the packages of the active profiles; nil means all.
+	profile map[string]bool
+	// tagged holds the packages passing the tag filters; nil means all.
+	tagged map[string]bool
+	// tagMatched holds the packages matching the tag filters themselves,
+	// without their dependencies; nil means all.
+	tagMatched map[string]bool
+	// sourceNames maps packages to the name their chosen alternative gives
+	// them in its source.
+	sourceNames map[string]string
 }
 
 func hashScript(s string) string {
@@ -66,88 +83,73 @@ func CompareVersions(a, b string) int {
 }
 
 func NormalizeVersion(s string) string {
-	return normalizeVersion(s)
+	return version.Normalize(s)
 }
 
 func cmpVersion(a, b string) int {
-	logging.Debug(fmt.Sprintf("cmpVersion input: a=%q b=%q", a, b))
-	na := normalizeVersion(a)
-	nb := normalizeVersion(b)
-	logging.Debug(fmt.Sprintf("cmpVersion normalized: a=%q b=%q", na, nb))
-	va := splitNumeric(na)
-	vb := splitNumeric(nb)
-	logging.Debug(fmt.Sprintf("cmpVersion numeric: a=%v b=%v", va, vb))
-	la, lb := len(va), len(vb)
-	n := la
-	if lb > n {
-		n = lb
-	}
-	for i := 0; i < n; i++ {
-		ai := 0
-		bi := 0
-		if i < la {
-			ai = va[i]
-		}
-		if i < lb {
-			bi = vb[i]
-		}
-		if ai > bi {
-			return 1
-		}
-		if ai < bi {
-			return -1
+	return version.Numeric.Compare(a, b)
+}
+
+// schemeFor returns the version scheme of a package: its own version_scheme,
+// else that of its source, else numeric.
+func (m *Manager) schemeFor(name string) version.Scheme {
+	name = strings.TrimSpace(name)
+	scheme := ""
+	switch {
+	case m.isCustom(name):
+		scheme = m.customByName(name).VersionScheme
+	case m.isGithubRelease(name):
+		scheme = m.githubByName(name).VersionScheme
+	default:
+		p := m.pkgByName(name)
+		scheme = p.VersionScheme
+		if scheme == "" {
+			scheme = m.sourceByName(p.Source).VersionScheme
 		}
 	}
-	return 0
+	s, err := version.ParseScheme(scheme)
+	if err != nil {
+		logging.Debug(err.Error())
+		return version.Numeric
+	}
+	return s
 }
 
-func normalizeVersion(s string) string {
-	s = strings.TrimSpace(s)
-	start := 0
-	for start < len(s) && (s[start] < '0' || s[start] > '9') {
-		start++
-	}
-	s = s[start:]
-	end := 0
-	for end < len(s) {
-		c := s[end]
-		if (c < '0' || c > '9') && c != '.' {
-			break
-		}
-		end++
-	}
-	return s[:end]
+// VersionScheme returns the name of the version scheme used for the package.
+func (m *Manager) VersionScheme(k PackageKey) string {
+	return string(m.schemeFor(k.Name))
 }
 
-func splitNumeric(s string) []int {
-	if s == "" {
-		return []int{}
-	}
-	parts := strings.Split(s, ".")
-	out := make([]int, 0, len(parts))
-	for _, p := range parts {
-		if p == "" {
-			out = append(out, 0)
-			continue
-		}
-		n, err := strconv.Atoi(p)
-		if err != nil {
-			n = 0
-		}
-		out = append(out, n)
-	}
-	return out
+func (m *Manager) compareVersions(name, a, b string) int {
+	scheme := m.schemeFor(name)
+	r := scheme.Compare(a, b)
+	logging.Debug(fmt.Sprintf("compare %s versions (%s): %q vs %q = %d", name, scheme, a, b, r))
+	return r
 }
 
 func New(cfg config.Config) *Manager {
-	m := &Manager{
-		cfg:         cfg,
-		ghClient:    ghapi.NewClient(),
-		customByIdx: make(map[string]int, len(cfg.CustomPackages)),
-		ghByIdx:     make(map[string]int, len(cfg.GithubReleasePackages)),
-		pkgByIdx:    make(map[string]int, len(cfg.Packages)),
-		sourceByIdx: make(map[string]int, len(cfg.Sources)),
-	}
+	m := &Manager{base: cfg, ghClient: ghapi.NewClient()}
+	m.load()
+	return m
+}
+
+// load derives the configuration the manager works with from the loaded one:
+// it chooses the alternatives of packages, expands variables and indexes
+// packages and sources by name.
+func (m *Manager) load() {
+	cfg, names := chooseAlternatives(m.base, func(name string) (state.PackageState, bool) {
+		if m.state == nil {
+			return state.PackageState{}, false
+		}
+		return m.state.GetPackageState(name)
+	})
+	cfg = expandConfigVars(cfg)
+	m.cfg = cfg
+	m.sourceNames = names
+	m.customByIdx = make(map[string]int, len(cfg.CustomPackages))
+	m.ghByIdx = make(map[string]int, len(cfg.GithubReleasePackages))
+	m.pkgByIdx = make(map[string]int, len(cfg.Packages))
+	m.sourceByIdx = make(map[string]int, len(cfg.Sources))
 	for i, cp := range cfg.CustomPackages {
 		m.customByIdx[cp.Name] = i
 	}
@@ -160,10 +162,12 @@ func New(cfg config.Config) *Manager {
 	for i, s := range cfg.Sources {
 		m.sourceByIdx[s.Name] = i
 	}
-	return m
 }
 
 func (m *Manager) Install(name string) error {
+	if err := m.checkSelected(name); err != nil {
+		return err
+	}
 	plan, err := m.resolve(name)
 	if err != nil {
 		return err
@@ -180,26 +184,48 @@ func (m *Manager) Install(name string) error {
 			if cp.Install.Command == "" {
 				return fmt.Errorf("missing install script for custom package: %s", n)
 			}
-			if err := m.runCtx(n, "install", cp.Install); err != nil {
+			inst := cp.Install
+			if _, pinned, err := m.lockedEntry(n); err != nil {
+				return err
+			} else if pinned || strings.TrimSpace(cp.Version) != "" {
+				v, err := m.customLatestVersion(cp)
+				if err != nil {
+					return err
+				}
+				if v == "" {
+					return fmt.Errorf("no version of %s satisfies version %q", n, cp.Version)
+				}
+				inst = withCommandVars(inst, "latest_version", v)
+			}
+			if err := m.runCtx(n, "install", inst); err != nil {
 				return err
 			}
+			m.recordInstalled(n)
 			logging.Success("installed: " + n)
 		} else if m.isGithubRelease(n) {
 			gp := m.githubByName(n)
 			if err := m.installGithubRelease(gp); err != nil {
 				return err
 			}
+			m.recordInstalled(n)
 			logging.Success("installed: " + n)
 		} else {
 			p := m.pkgByName(n)
 			s := m.sourceByName(p.Source)
-			expanded, err := expandCommandForName(s.Install, n)
+			if err := m.checkSourceCandidate(n, s.Install); err != nil {
+				return err
+			}
+			expanded, err := expandCommandForName(s.Install, m.sourcePackageName(n))
 			if err != nil {
 				return fmt.Errorf("invalid placeholders for source %s [install]: %w", s.Name, err)
 			}
+			if expanded, err = m.expandSourceVersion(expanded, n); err != nil {
+				return err
+			}
 			if err := m.runCtx(n, "install", expanded); err != nil {
 				return err
 			}
+			m.recordInstalled(n)
 			logging.Success("installed: " + n)
 		}
 	}
@@ -207,39 +233,69 @@ func (m *Manager) Install(name string) error {
 }
 
 func (m *Manager) Remove(name string) error {
+	if err := m.checkRemovable(name); err != nil {
+		return err
+	}
+	if err := m.remove(name); err != nil {
+		return err
+	}
+	m.forgetInstalled(name)
+	return nil
+}
+
+func (m *Manager) remove(name string) error {
+	cmd, err := m.removeCommand(name)
+	if err != nil {
+		return err
+	}
+	return m.runCtx(name, "remove", cmd)
+}
+
+func (m *Manager) removeCommand(name string) (config.Command, error) {
 	if m.isCustom(name) {
 		cp := m.customByName(name)
 		if cp.Remove.Command == "" {
-			return fmt.Errorf("missing remove script for custom package: %s", name)
+			return config.Command{}, fmt.Errorf("missing remove script for custom package: %s", name)
 		}
-		return m.runCtx(name, "remove", cp.Remove)
+		return cp.Remove, nil
 	}
 	if m.isGithubRelease(name) {
 		gp := m.githubByName(name)
 		if gp.Remove.Command == "" {
-			return fmt.Errorf("missing remove script for github release package: %s", name)
+			return config.Command{}, fmt.Errorf("missing remove script for github release package: %s", name)
 		}
-		return m.runCtx(name, "remove", gp.Remove)
+		return gp.Remove, nil
 	}
 	p := m.pkgByName(name)
 	if p.Name == "" {
-		return errors.New("unknown package: " + name)
+		return config.Command{}, errors.New("unknown package: " + name)
 	}
 	s := m.sourceByName(p.Source)
 	if s.Name == "" {
-		return fmt.Errorf("unknown source: %s", p.Source)
+		return config.Command{}, fmt.Errorf("unknown source: %s", p.Source)
 	}
 	if s.Remove.Command == "" {
-		return fmt.Errorf("missing remove script for source: %s", s.Name)
+		return config.Command{}, fmt.Errorf("missing remove script for source: %s", s.Name)
 	}
-	expanded, err := expandCommandForName(s.Remove, name)
+	expanded, err := expandCommandForName(s.Remove, m.sourcePackageName(name))
 	if err != nil {
-		return fmt.Errorf("invalid placeholders for source %s [remove]: %w", s.Name, err)
+		return config.Command{}, fmt.Errorf("invalid placeholders for source %s [remove]: %w", s.Name, err)
 	}
-	return m.runCtx(name, "remove", expanded)
+	return expanded, nil
 }
 
 func (m *Manager) UpdateOne(name string) error {
+	if err := m.checkSelected(name); err != nil {
+		return err
+	}
+	if err := m.updateOne(name); err != nil {
+		return err
+	}
+	m.recordInstalled(name)
+	return nil
+}
+
+func (m *Manager) updateOne(name string) error {
 	logging.Debug("update one: " + name)
 	if m.isCustom(name) {
 		return m.updateCustom(m.customByName(name))
@@ -249,10 +305,16 @@ func (m *Manager) UpdateOne(name string) error {
 	}
 	p := m.pkgByName(name)
 	s := m.sourceByName(p.Source)
-	expanded, err := expandCommandForName(s.Update, name)
+	if err := m.checkSourceCandidate(name, s.Update); err != nil {
+		return err
+	}
+	expanded, err := expandCommandForName(s.Update, m.sourcePackageName(name))
 	if err != nil {
 		return fmt.Errorf("invalid placeholders for source %s [update]: %w", s.Name, err)
 	}
+	if expanded, err = m.expandSourceVersion(expanded, name); err != nil {
+		return err
+	}
 	if err := m.runCtx(name, "update", expanded); err != nil {
 		return err
 	}
@@ -295,9 +357,10 @@ func (m *Manager) Search(query string) error {
 		if s.Search.Command == "" {
 			continue
 		}
-		cmd := strings.ReplaceAll(s.Search.Command, "{query}", query)
-		logging.Debug(fmt.Sprintf("%s [search]: %s", s.Name, cmd))
-		res := executil.RunShell(config.Command{Command: cmd, RequireRoot: s.Search.RequireRoot})
+		cmd := s.Search
+		cmd.Command = config.ReplaceRef(s.Search.Command, "{query}", query)
+		logging.Debug(fmt.Sprintf("%s [search]: %s", s.Name, cmd.Command))
+		res := executil.RunShell(cmd)
 		if res.Stdout != "" {
 			fmt.Print(res.Stdout)
 		}
@@ -310,29 +373,23 @@ func (m *Manager) Search(query string) error {
 
 func (m *Manager) updateCustom(cp config.CustomPackage) error {
 	need := false
-	latest := ""
 	installed := ""
 
-	if cp.GetLatestVersion.Command != "" {
-		res := executil.RunShell(cp.GetLatestVersion)
-		if res.Code != 0 {
-			return fmt.Errorf("command failed for %s [get_latest_version]: exit %d\n%s", cp.Name, res.Code, res.Stderr)
-		}
-		latest = strings.TrimSpace(res.Stdout)
+	latest, err := m.customLatestVersion(cp)
+	if err != nil {
+		return err
 	}
 	if cp.GetInstalledVersion.Command != "" {
 		logging.Debug(fmt.Sprintf("%s [get_installed_version]: %s", cp.Name, cp.GetInstalledVersion.Command))
-		res := executil.RunShell(cp.GetInstalledVersion)
-		if res.Code != 0 {
-			return fmt.Errorf("command failed for %s [get_installed_version]: exit %d\n%s", cp.Name, res.Code, res.Stderr)
+		if installed, err = m.readVersion(cp.Name, "get_installed_version", cp.GetInstalledVersion); err != nil {
+			return err
 		}
-		installed = strings.TrimSpace(res.Stdout)
 		logging.Debug(fmt.Sprintf("%s [get_installed_version result]: %s", cp.Name, installed))
 	}
 	if installed == "" && cp.Install.Command != "" {
 		need = true
 	} else if latest != "" {
-		need = cmpVersion(latest, installed) > 0
+		need = m.compareVersions(cp.Name, latest, installed) > 0
 	} else {
 		need = false
 	}
@@ -346,8 +403,7 @@ func (m *Manager) updateCustom(cp config.CustomPackage) error {
 		if cp.Install.Command == "" {
 			return fmt.Errorf("missing install script for custom package: %s", cp.Name)
 		}
-		inst := fmt.Sprintf("latest_version=%q installed_version=%q; %s", latest, installed, cp.Install.Command)
-		if err := m.runCtx(cp.Name, "install", config.Command{Command: inst, RequireRoot: cp.Install.RequireRoot}); err != nil {
+		if err := m.runCtx(cp.Name, "install", withVersionEnv(cp.Install, latest, installed)); err != nil {
 			return err
 		}
 		logging.Success("updated: " + cp.Name)
@@ -372,6 +428,9 @@ func (m *Manager) KeyForName(name string) (PackageKey, error) {
 }
 
 func (m *Manager) ResolveKeys(name string) ([]PackageKey, error) {
+	if err := m.checkSelected(name); err != nil {
+		return nil, err
+	}
 	plan, err := m.resolve(name)
 	if err != nil {
 		return nil, err
@@ -388,22 +447,13 @@ func (m *Manager) ResolveKeys(name string) ([]PackageKey, error) {
 }
 
 func (m *Manager) resolve(name string) ([]string, error) {
-	nodes := map[string][]string{}
-	for _, p := range m.cfg.Packages {
-		nodes[p.Name] = append([]string{}, p.DependsOn...)
-	}
-	for _, c := range m.cfg.CustomPackages {
-		nodes[c.Name] = append([]string{}, c.DependsOn...)
-	}
-	for _, g := range m.cfg.GithubReleasePackages {
-		nodes[g.Name] = append([]string{}, g.DependsOn...)
-	}
+	nodes := m.dependencyGraph()
 	if _, ok := nodes[name]; !ok {
 		return nil, errors.New("unknown package: " + name)
 	}
-	ord, ok := topoOrder(nodes)
-	if !ok {
-		return nil, errors.New("dependency cycle")
+	ord, err := topoOrder(nodes)
+	if err != nil {
+		return nil, err
 	}
 	closure := map[string]bool{}
 	var visit func(n string)
@@ -468,13 +518,13 @@ func (m *Manager) runCtx(name string, step string, command config.Command) error
 	logging.Debug(fmt.Sprintf("%s [%s]: %s", name, step, command.Command))
 	res := executil.RunShell(command)
 	if res.Stdout != "" {
-		fmt.Print(res.Stdout)
+		fmt.Print(logging.Redact(res.Stdout))
 	}
 	if res.Stderr != "" {
-		fmt.Print(res.Stderr)
+		fmt.Print(logging.Redact(res.Stderr))
 	}
 	if res.Code != 0 {
-		errLine := strings.TrimSpace(res.Stderr)
+		errLine := strings.TrimSpace(logging.Redact(res.Stderr))
 		if i := strings.IndexByte(errLine, '\n'); i >= 0 {
 			errLine = errLine[:i]
 		}
@@ -487,12 +537,9 @@ func (m *Manager) runCtx(name string, step string, command config.Command) error
 }
 
 func (m *Manager) installGithubRelease(gp config.GithubReleasePackage) error {
-	installed := ""
-	if gp.GetInstalledVersion.Command != "" {
-		res := executil.RunShell(gp.GetInstalledVersion)
-		if res.Code == 0 {
-			installed = strings.TrimSpace(res.Stdout)
-		}
+	installed, err := m.probeVersion(gp.Name, gp.GetInstalledVersion)
+	if err != nil {
+		return err
 	}
 	if installed != "" {
 		return nil
@@ -504,12 +551,9 @@ func (m *Manager) installGithubRelease(gp config.GithubReleasePackage) error {
 }
 
 func (m *Manager) updateGithubRelease(gp config.GithubReleasePackage) error {
-	installed := ""
-	if gp.GetInstalledVersion.Command != "" {
-		res := executil.RunShell(gp.GetInstalledVersion)
-		if res.Code == 0 {
-			installed = strings.TrimSpace(res.Stdout)
-		}
+	installed, err := m.probeVersion(gp.Name, gp.GetInstalledVersion)
+	if err != nil {
+		return err
 	}
 	if installed == "" {
 		return nil
@@ -521,24 +565,23 @@ func (m *Manager) updateGithubRelease(gp config.GithubReleasePackage) error {
 }
 
 func (m *Manager) installOrUpdateGithubRelease(gp config.GithubReleasePackage, installed string) error {
-	rel, err := m.ghClient.GetLatestRelease(gp.Repo)
+	rel, err := m.githubRelease(gp)
 	if err != nil {
 		return err
 	}
-	latest := strings.TrimSpace(rel.TagName)
-	if installed != "" && latest != "" && cmpVersion(latest, installed) <= 0 {
-		return nil
-	}
-	asset, err := m.ghClient.FindAsset(rel, gp.AssetPattern)
+	latest, err := m.releaseVersion(gp, rel)
 	if err != nil {
 		return err
 	}
+	if installed != "" && latest != "" && m.compareVersions(gp.Name, latest, installed) <= 0 {
+		return nil
+	}
 	tmpDir, err := os.MkdirTemp("", "gopak-"+gp.Name+"-")
 	if err != nil {
 		return err
 	}
 	defer os.RemoveAll(tmpDir)
-	path, err := m.ghClient.DownloadAsset(asset, tmpDir)
+	path, err := m.downloadGithubAsset(gp, rel, tmpDir)
 	if err != nil {
 		return err
 	}
@@ -547,15 +590,5 @@ func (m *Manager) installOrUpdateGithubRelease(gp config.GithubReleasePackage, i
 }
 
 func githubPostInstallCommand(gp config.GithubReleasePackage, latest, installed, assetPath string) config.Command {
-	cmd := gp.PostInstall
-	if runtime.GOOS == "windows" {
-		cmd.Command = fmt.Sprintf("set \"latest_version=%s\" && set \"installed_version=%s\" && set \"asset_path=%s\" && %s", cmdSetValue(latest), cmdSetValue(installed), cmdSetValue(assetPath), cmd.Command)
-		return cmd
-	}
-	cmd.Command = fmt.Sprintf("latest_version=%q installed_version=%q asset_path=%q; %s", latest, installed, assetPath, cmd.Command)
-	return cmd
-}
-
-func cmdSetValue(s string) string {
-	return strings.ReplaceAll(s, "\"", "\"\"")
+	return withCommandVars(gp.PostInstall, "latest_version", latest, "installed_version", installed, "asset_path", assetPath)
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/version"
 )
 
 func TestRemoveUnknownPackage(t *testing.T) {
@@ -137,3 +138,41 @@ func TestCmpVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestSchemeFor_InheritsFromSource(t *testing.T) {
+	cfg := config.Config{
+		Sources: []config.Source{{Name: "apt", VersionScheme: "debian"}},
+		Packages: []config.Package{
+			{Name: "git", Source: "apt"},
+			{Name: "calc", Source: "apt", VersionScheme: "calver"},
+		},
+		CustomPackages:        []config.CustomPackage{{Name: "tool", VersionScheme: "semver"}},
+		GithubReleasePackages: []config.GithubReleasePackage{{Name: "gh"}},
+	}
+	m := New(cfg)
+	cases := map[string]version.Scheme{"git": version.Debian, "calc": version.Calver, "tool": version.Semver, "gh": version.Numeric}
+	for name, want := range cases {
+		if got := m.schemeFor(name); got != want {
+			t.Errorf("schemeFor(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestUpdateSelected_Custom_UsesScheme(t *testing.T) {
+	cfg := config.Config{CustomPackages: []config.CustomPackage{{
+		Name:                "tool",
+		VersionScheme:       "semver",
+		GetInstalledVersion: config.Command{Command: "echo 1.0.0-rc.1"},
+		GetLatestVersion:    config.Command{Command: "echo 1.0.0"},
+		Update:              config.Command{Command: "true"},
+	}}}
+	m := New(cfg)
+	run := &mockRunner{}
+	key := PackageKey{Source: "custom", Name: "tool", Kind: "custom"}
+	if err := m.UpdateSelected([]PackageKey{key}, run, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(run.calls) != 1 {
+		t.Fatalf("release must replace its prerelease under semver, got %v", run.calls)
+	}
+}
@@ -0,0 +1,185 @@
+package manager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/executil"
+	ghapi "github.com/the-gopak/gopak-cli/internal/github"
+	"github.com/the-gopak/gopak-cli/internal/logging"
+)
+
+// versionRegexFor returns the version_regex of a package, falling back to the
+// one of its source for package-manager packages.
+func (m *Manager) versionRegexFor(name string) string {
+	switch {
+	case m.isCustom(name):
+		return m.customByName(name).VersionRegex
+	case m.isGithubRelease(name):
+		return m.githubByName(name).VersionRegex
+	}
+	p := m.pkgByName(name)
+	if p.VersionRegex != "" {
+		return p.VersionRegex
+	}
+	return m.sourceByName(p.Source).VersionRegex
+}
+
+// extractVersion pulls the version of name out of raw command output or a
+// release tag: the version group of its version_regex. Without a regex the
+// trimmed output is the version. Empty output stays empty; output the regex
+// does not match is an error.
+func (m *Manager) extractVersion(name, raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	expr := m.versionRegexFor(name)
+	if raw == "" || expr == "" {
+		return raw, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid version_regex for %s: %w", name, err)
+	}
+	i := re.SubexpIndex("version")
+	if i < 0 {
+		return "", fmt.Errorf("invalid version_regex for %s: must name its capture group (?P<version>...)", name)
+	}
+	match := re.FindStringSubmatch(raw)
+	if match == nil {
+		return "", fmt.Errorf("version_regex %q did not match %q for %s", expr, firstLine(raw), name)
+	}
+	return strings.TrimSpace(match[i]), nil
+}
+
+// probeVersion runs a version command for name and extracts the version from
+// its output. A command that fails reports no version rather than an error,
+// as get_installed_version commonly fails for packages that are not installed.
+func (m *Manager) probeVersion(name string, cmd config.Command) (string, error) {
+	if cmd.Command == "" {
+		return "", nil
+	}
+	res := executil.RunShell(cmd)
+	if res.Code != 0 {
+		return "", nil
+	}
+	return m.extractVersion(name, res.Stdout)
+}
+
+// readVersion is like probeVersion but treats a failing command as an error.
+func (m *Manager) readVersion(name, step string, cmd config.Command) (string, error) {
+	res := executil.RunShell(cmd)
+	if res.Code != 0 {
+		return "", fmt.Errorf("command failed for %s [%s]: exit %d\n%s", name, step, res.Code, res.Stderr)
+	}
+	return m.extractVersion(name, res.Stdout)
+}
+
+// releaseVersion returns the version of gp published as rel.
+func (m *Manager) releaseVersion(gp config.GithubReleasePackage, rel *ghapi.Release) (string, error) {
+	return m.extractVersion(gp.Name, rel.TagName)
+}
+
+// ProbeInstalled returns the installed version of k, or an error when its
+// output cannot be parsed.
+func (m *Manager) ProbeInstalled(k PackageKey) (string, error) {
+	return m.getVersionInstalled(k)
+}
+
+// ProbeAvailable returns the version k would be installed or updated to. With
+// dryRun the source's pre_update step is skipped.
+func (m *Manager) ProbeAvailable(k PackageKey, dryRun bool) (string, error) {
+	return m.getVersionAvailable(k, dryRun)
+}
+
+func (m *Manager) GetVersionInstalled(k PackageKey) string {
+	v, err := m.getVersionInstalled(k)
+	if err != nil {
+		logging.Debug(err.Error())
+	}
+	return v
+}
+
+func (m *Manager) GetVersionAvailable(k PackageKey) string {
+	v, err := m.getVersionAvailable(k, false)
+	if err != nil {
+		logging.Debug(err.Error())
+	}
+	return v
+}
+
+func (m *Manager) GetVersionAvailableDryRun(k PackageKey) string {
+	v, err := m.getVersionAvailable(k, true)
+	if err != nil {
+		logging.Debug(err.Error())
+	}
+	return v
+}
+
+func (m *Manager) getVersionInstalled(k PackageKey) (string, error) {
+	switch k.Kind {
+	case "custom":
+		return m.probeVersion(k.Name, m.customByName(k.Name).GetInstalledVersion)
+	case "github":
+		return m.probeVersion(k.Name, m.githubByName(k.Name).GetInstalledVersion)
+	}
+	src := m.sourceByName(k.Source)
+	if src.Name == "" || src.GetInstalledVersion.Command == "" {
+		return "", nil
+	}
+	expanded, err := expandCommandForName(src.GetInstalledVersion, m.sourcePackageName(k.Name))
+	if err != nil {
+		return "", nil
+	}
+	return m.probeVersion(k.Name, expanded)
+}
+
+func (m *Manager) getVersionAvailable(k PackageKey, dryRun bool) (string, error) {
+	if v, ok := m.lockedVersion(k.Name); ok {
+		return v, nil
+	}
+	switch k.Kind {
+	case "custom":
+		v, err := m.probeVersion(k.Name, m.customByName(k.Name).GetLatestVersion)
+		if err != nil {
+			return "", err
+		}
+		return m.constrain(k.Name, v)
+	case "github":
+		if _, _, err := m.constraintFor(k.Name); err != nil {
+			return "", err
+		}
+		gp := m.githubByName(k.Name)
+		rel, err := m.latestGithubRelease(gp)
+		if err != nil {
+			return "", nil
+		}
+		return m.releaseVersion(gp, rel)
+	}
+	src := m.sourceByName(k.Source)
+	if src.Name == "" {
+		return "", nil
+	}
+	if !dryRun {
+		m.ensurePreUpdate(src)
+	}
+	if src.GetLatestVersion.Command == "" {
+		return "", nil
+	}
+	expanded, err := expandCommandForName(src.GetLatestVersion, m.sourcePackageName(k.Name))
+	if err != nil {
+		return "", nil
+	}
+	v, err := m.probeVersion(k.Name, expanded)
+	if err != nil {
+		return "", err
+	}
+	return m.constrain(k.Name, v)
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
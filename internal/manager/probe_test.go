@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	ghapi "github.com/the-gopak/gopak-cli/internal/github"
+)
+
+func TestProbeInstalled_VersionRegex(t *testing.T) {
+	cfg := config.Config{
+		Sources: []config.Source{{
+			Name:                "apt",
+			GetInstalledVersion: config.Command{Command: "echo '{package} version 2.43.0 (build 7)'"},
+			VersionRegex:        `version (?P<version>[0-9.]+)`,
+		}},
+		Packages: []config.Package{{Name: "git", Source: "apt"}},
+		CustomPackages: []config.CustomPackage{{
+			Name:                "tool",
+			GetInstalledVersion: config.Command{Command: "printf 'tool\\nrelease: v1.4.2\\n'"},
+			VersionRegex:        `release: v(?P<version>\S+)`,
+		}},
+	}
+	m := New(cfg)
+	if v, err := m.ProbeInstalled(PackageKey{Source: "apt", Name: "git", Kind: "source"}); err != nil || v != "2.43.0" {
+		t.Fatalf("source regex: %q, %v", v, err)
+	}
+	if v, err := m.ProbeInstalled(PackageKey{Source: "custom", Name: "tool", Kind: "custom"}); err != nil || v != "1.4.2" {
+		t.Fatalf("custom regex: %q, %v", v, err)
+	}
+}
+
+func TestProbeInstalled_RegexMismatchIsError(t *testing.T) {
+	m := New(config.Config{CustomPackages: []config.CustomPackage{{
+		Name:                "tool",
+		GetInstalledVersion: config.Command{Command: "echo tool dev-build"},
+		VersionRegex:        `v(?P<version>[0-9.]+)`,
+	}}})
+	_, err := m.ProbeInstalled(PackageKey{Source: "custom", Name: "tool", Kind: "custom"})
+	if err == nil || !strings.Contains(err.Error(), "did not match") {
+		t.Fatalf("expected mismatch error, got %v", err)
+	}
+	if err := m.UpdateOne("tool"); err == nil {
+		t.Fatal("update must not compare an unparsed version")
+	}
+}
+
+func TestProbeAvailable_GithubTagRegex(t *testing.T) {
+	m := New(config.Config{GithubReleasePackages: []config.GithubReleasePackage{{
+		Name: "tool", Repo: "org/tool", AssetPattern: "tool-*",
+		VersionRegex: `^tool-(?P<version>.+)$`,
+	}}})
+	m.ghClient = &fakeGithub{latest: "tool-3.1.0", releases: map[string]*ghapi.Release{
+		"tool-3.1.0": {TagName: "tool-3.1.0"},
+	}}
+	v, err := m.ProbeAvailable(PackageKey{Source: "github", Name: "tool", Kind: "github"}, true)
+	if err != nil || v != "3.1.0" {
+		t.Fatalf("tag regex: %q, %v", v, err)
+	}
+}
+
+func TestExtractVersion_RequiresVersionGroup(t *testing.T) {
+	m := New(config.Config{CustomPackages: []config.CustomPackage{{Name: "tool", VersionRegex: `[0-9.]+`}}})
+	if v, err := m.extractVersion("tool", "tool 1.2.3"); err == nil {
+		t.Fatalf("a regex without a version group must be an error, got %q", v)
+	}
+}
@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/logging"
+)
+
+// UseProfiles limits the packages Gopak considers to those listed in the
+// named profiles and everything they depend on. A profile entry names a
+// package or a tag, which stands for every package carrying it. Without
+// profiles every configured package is considered. Entries naming neither a
+// package nor a tag configured on this host are skipped.
+func (m *Manager) UseProfiles(names []string) error {
+	if len(names) == 0 {
+		m.profile = nil
+		return nil
+	}
+	nodes := m.dependencyGraph()
+	tagged := m.packagesByTag()
+	var roots []string
+	for _, p := range names {
+		entries, ok := m.cfg.Profiles[p]
+		if !ok {
+			return fmt.Errorf("unknown profile %q (available: %s)", p, strings.Join(m.ProfileNames(), ", "))
+		}
+		for _, e := range entries {
+			if _, ok := nodes[e]; ok {
+				roots = append(roots, e)
+				continue
+			}
+			if pkgs, ok := tagged[e]; ok {
+				roots = append(roots, pkgs...)
+				continue
+			}
+			logging.Debug(fmt.Sprintf("profile %s: skipping %s, not configured on this host", p, e))
+		}
+	}
+	m.profile = dependencyClosure(nodes, roots, nil)
+	return nil
+}
+
+// ProfileNames returns the configured profile names, sorted.
+func (m *Manager) ProfileNames() []string {
+	out := make([]string, 0, len(m.cfg.Profiles))
+	for p := range m.cfg.Profiles {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// inProfile reports whether name belongs to the active profiles.
+func (m *Manager) inProfile(name string) bool {
+	return m.profile == nil || m.profile[name]
+}
+
+// considered reports whether name passes both the active profiles and the
+// tag filters.
+func (m *Manager) considered(name string) bool {
+	return m.inProfile(name) && m.inTags(name)
+}
+
+// checkSelected rejects explicit operations on packages outside the active
+// profiles or tag filters.
+func (m *Manager) checkSelected(name string) error {
+	if !m.inProfile(name) {
+		return fmt.Errorf("package %s is not in the active profiles", name)
+	}
+	return m.checkTags(name)
+}
+
+// dependencyClosure returns roots and everything they depend on, leaving out
+// the packages in exclude and what is only reachable through them.
+func dependencyClosure(nodes map[string][]string, roots []string, exclude map[string]bool) map[string]bool {
+	out := map[string]bool{}
+	var visit func(n string)
+	visit = func(n string) {
+		if out[n] || exclude[n] {
+			return
+		}
+		out[n] = true
+		for _, d := range nodes[n] {
+			visit(d)
+		}
+	}
+	for _, r := range roots {
+		visit(r)
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+)
+
+func profileConfig() config.Config {
+	cfg := graphConfig()
+	cfg.Profiles = map[string][]string{
+		"server":  {"curl"},
+		"desktop": {"tool", "not-on-this-host"},
+	}
+	return cfg
+}
+
+func TestUseProfiles_TracksProfilePackagesAndDependencies(t *testing.T) {
+	m := New(profileConfig())
+	if err := m.UseProfiles([]string{"desktop"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"apt":    {"curl", "git"},
+		"custom": {"helper", "tool"},
+	}
+	if got := m.Tracked(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tracked() = %v, want %v", got, want)
+	}
+	if err := m.Install("lazygit"); err == nil || !strings.Contains(err.Error(), "not in the active profiles") {
+		t.Fatalf("expected profile error, got %v", err)
+	}
+	if _, err := m.ResolveKeys("lazygit"); err == nil {
+		t.Fatal("expected profile error from ResolveKeys")
+	}
+}
+
+func TestUseProfiles_Several(t *testing.T) {
+	m := New(profileConfig())
+	if err := m.UseProfiles([]string{"server", "desktop"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.Tracked()["apt"]); got != 2 {
+		t.Fatalf("apt packages = %d, want 2", got)
+	}
+	if err := m.UseProfiles(nil); err != nil || len(m.Tracked()["github"]) != 1 {
+		t.Fatalf("no profile should track everything: %v", m.Tracked())
+	}
+}
+
+func TestUseProfiles_Unknown(t *testing.T) {
+	m := New(profileConfig())
+	err := m.UseProfiles([]string{"laptop"})
+	if err == nil || !strings.Contains(err.Error(), "available: desktop, server") {
+		t.Fatalf("expected unknown profile error, got %v", err)
+	}
+}
@@ -1,15 +1,17 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/executil"
+	"github.com/the-gopak/gopak-cli/internal/logging"
 )
 
 type Runner interface {
@@ -68,28 +70,24 @@ func (r *SudoRunner) ensureRootAccess(name, command string) bool {
 }
 
 func (r *SudoRunner) Run(name, step string, cmd config.Command) error {
-	final := cmd.Command
-	if runtime.GOOS == "windows" {
-		bcmd := exec.Command("cmd", "/C", final)
-		bcmd.Stdout = os.Stdout
-		bcmd.Stderr = os.Stderr
-		if err := bcmd.Run(); err != nil {
-			return fmt.Errorf("command failed for %s [%s]", name, step)
-		}
-		return nil
-	}
-	if cmd.RequireRoot {
+	if cmd.RequireRoot && runtime.GOOS != "windows" {
 		if !r.ensureRootAccess(name, cmd.Command) {
 			return fmt.Errorf("sudo auth not granted for %s [%s]", name, step)
 		}
-
-		esc := strings.ReplaceAll(final, "'", "'\"'\"'")
-		final = fmt.Sprintf("sudo -n bash -ceu '%s'", esc)
 	}
-	bcmd := exec.Command("bash", "-ceu", final)
-	bcmd.Stdout = os.Stdout
-	bcmd.Stderr = os.Stderr
-	if err := bcmd.Run(); err != nil {
+	p, err := executil.Command(cmd, true)
+	if err != nil {
+		return fmt.Errorf("command failed for %s [%s]: %w", name, step, err)
+	}
+	stdout, stderr := logging.NewRedactWriter(os.Stdout), logging.NewRedactWriter(os.Stderr)
+	p.Stdout, p.Stderr = stdout, stderr
+	err = p.Run()
+	_ = stdout.Flush()
+	_ = stderr.Flush()
+	if err != nil {
+		if errors.Is(err, executil.ErrTimeout) {
+			return fmt.Errorf("command %s for %s [%s]", err, name, step)
+		}
 		return fmt.Errorf("command failed for %s [%s]", name, step)
 	}
 	return nil
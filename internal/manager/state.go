@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/logging"
+	"github.com/the-gopak/gopak-cli/internal/state"
+)
+
+// UseState attaches the persistent record of packages managed by Gopak.
+// Without it the manager performs operations without remembering them.
+func (m *Manager) UseState(st *state.Manager) {
+	m.state = st
+	// Installed packages keep the alternative recorded in the state.
+	m.load()
+}
+
+// recordInstalled stores the package after a successful install or update.
+// Failures to persist are logged rather than returned so that a completed
+// package operation is never reported as failed.
+func (m *Manager) recordInstalled(name string) {
+	if m.state == nil {
+		return
+	}
+	k, err := m.KeyForName(name)
+	if err != nil {
+		return
+	}
+	version, err := m.getVersionInstalled(k)
+	if err != nil {
+		logging.Debug(fmt.Sprintf("state: %v", err))
+	}
+	if version == "" && m.hasInstalledProbe(k) {
+		logging.Debug(fmt.Sprintf("state: %s reports no installed version, not recorded", name))
+		return
+	}
+	ps := state.PackageState{
+		Kind:        k.Kind,
+		Source:      k.Source,
+		Version:     version,
+		InstalledAt: time.Now().UTC().Format(time.RFC3339),
+		ConfigHash:  m.configHash(name),
+	}
+	if cmd, err := m.removeCommand(name); err == nil {
+		ps.Remove = &cmd
+	}
+	if prev, ok := m.state.GetPackageState(name); ok {
+		ps.FileChecksums = prev.FileChecksums
+	}
+	if err := m.state.SetPackageState(name, ps); err != nil {
+		logging.Debug(fmt.Sprintf("state: could not record %s: %v", name, err))
+	}
+}
+
+// forgetInstalled drops the package from the state after a successful remove.
+func (m *Manager) forgetInstalled(name string) {
+	if m.state == nil {
+		return
+	}
+	if _, ok := m.state.GetPackageState(name); !ok {
+		return
+	}
+	if err := m.state.RemovePackageState(name); err != nil {
+		logging.Debug(fmt.Sprintf("state: could not forget %s: %v", name, err))
+	}
+}
+
+// Orphans returns the packages recorded in the state that no longer appear
+// in the configuration, sorted by name.
+func (m *Manager) Orphans() []PackageKey {
+	if m.state == nil {
+		return nil
+	}
+	var out []PackageKey
+	for _, name := range m.state.Names() {
+		if _, err := m.KeyForName(name); err == nil {
+			continue
+		}
+		ps, _ := m.state.GetPackageState(name)
+		out = append(out, PackageKey{Source: ps.Source, Name: name, Kind: ps.Kind})
+	}
+	return out
+}
+
+// RemoveOrphan removes a package that is only known from the state file,
+// using the remove command recorded when it was installed. Packages recorded
+// without one fall back to the remove command of their source, if it is
+// still configured.
+func (m *Manager) RemoveOrphan(k PackageKey, runner Runner) error {
+	if m.state == nil {
+		return errors.New("no state available to remove: " + k.Name)
+	}
+	ps, ok := m.state.GetPackageState(k.Name)
+	if !ok {
+		return errors.New("not managed by gopak: " + k.Name)
+	}
+	var cmd config.Command
+	if ps.Remove != nil {
+		cmd = *ps.Remove
+	} else if s := m.sourceByName(ps.Source); s.Remove.Command != "" {
+		expanded, err := expandCommandForName(s.Remove, k.Name)
+		if err != nil {
+			return fmt.Errorf("invalid placeholders for source %s [remove]: %w", s.Name, err)
+		}
+		cmd = expanded
+	}
+	if cmd.Command == "" {
+		return fmt.Errorf("no remove command recorded for %s", k.Name)
+	}
+	if err := runner.Run(k.Name, "remove", cmd); err != nil {
+		return err
+	}
+	if err := m.state.RemovePackageState(k.Name); err != nil {
+		logging.Debug(fmt.Sprintf("state: could not forget %s: %v", k.Name, err))
+	}
+	return nil
+}
+
+func (m *Manager) hasInstalledProbe(k PackageKey) bool {
+	switch k.Kind {
+	case "custom":
+		return m.customByName(k.Name).GetInstalledVersion.Command != ""
+	case "github":
+		return m.githubByName(k.Name).GetInstalledVersion.Command != ""
+	}
+	return m.sourceByName(k.Source).GetInstalledVersion.Command != ""
+}
+
+// configHash fingerprints the configuration entry of a package so that later
+// runs can tell whether the definition changed since it was applied.
+func (m *Manager) configHash(name string) string {
+	var entry any
+	switch {
+	case m.isCustom(name):
+		entry = m.customByName(name)
+	case m.isGithubRelease(name):
+		entry = m.githubByName(name)
+	default:
+		entry = m.pkgByName(name)
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return ""
+	}
+	return hashScript(string(b))
+}
@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/state"
+)
+
+func newStateForTest(t *testing.T) *state.Manager {
+	t.Helper()
+	st, err := state.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	return st
+}
+
+func TestInstall_RecordsState(t *testing.T) {
+	cfg := config.Config{CustomPackages: []config.CustomPackage{{
+		Name:                "tool",
+		GetInstalledVersion: config.Command{Command: "echo 1.2.3"},
+		Install:             config.Command{Command: "true"},
+	}}}
+	m := New(cfg)
+	st := newStateForTest(t)
+	m.UseState(st)
+	if err := m.Install("tool"); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	ps, ok := st.GetPackageState("tool")
+	if !ok {
+		t.Fatal("package was not recorded")
+	}
+	if ps.Kind != "custom" || ps.Source != "custom" || ps.Version != "1.2.3" {
+		t.Fatalf("unexpected state: %#v", ps)
+	}
+	if ps.InstalledAt == "" || ps.ConfigHash == "" {
+		t.Fatalf("install time and config hash must be recorded: %#v", ps)
+	}
+}
+
+func TestInstall_FailureKeepsPreviousState(t *testing.T) {
+	cfg := config.Config{CustomPackages: []config.CustomPackage{{
+		Name:                "tool",
+		GetInstalledVersion: config.Command{Command: "echo 2.0.0"},
+		Install:             config.Command{Command: "false"},
+	}}}
+	m := New(cfg)
+	st := newStateForTest(t)
+	prev := state.PackageState{Kind: "custom", Source: "custom", Version: "1.0.0", InstalledAt: "2024-01-01T00:00:00Z"}
+	if err := st.SetPackageState("tool", prev); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	m.UseState(st)
+	if err := m.Install("tool"); err == nil {
+		t.Fatal("expected install failure")
+	}
+	ps, _ := st.GetPackageState("tool")
+	if ps.Version != prev.Version || ps.InstalledAt != prev.InstalledAt {
+		t.Fatalf("failed install must not touch state: %#v", ps)
+	}
+}
+
+func TestRemove_ForgetsState(t *testing.T) {
+	cfg := config.Config{CustomPackages: []config.CustomPackage{{
+		Name:   "tool",
+		Remove: config.Command{Command: "true"},
+	}}}
+	m := New(cfg)
+	st := newStateForTest(t)
+	if err := st.SetPackageState("tool", state.PackageState{Version: "1.0.0"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	m.UseState(st)
+	if err := m.Remove("tool"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, ok := st.GetPackageState("tool"); ok {
+		t.Fatal("removed package must be dropped from state")
+	}
+}
+
+func TestExecuteSelected_RecordsOnlySuccessful(t *testing.T) {
+	cfg := config.Config{CustomPackages: []config.CustomPackage{{
+		Name:                "ok",
+		GetInstalledVersion: config.Command{Command: "echo 1.0.0"},
+		GetLatestVersion:    config.Command{Command: "echo 2.0.0"},
+		Update:              config.Command{Command: "true"},
+	}, {
+		Name:                "broken",
+		GetInstalledVersion: config.Command{Command: "echo 1.0.0"},
+		GetLatestVersion:    config.Command{Command: "exit 3"},
+		Update:              config.Command{Command: "true"},
+	}}}
+	m := New(cfg)
+	st := newStateForTest(t)
+	m.UseState(st)
+	keys := []PackageKey{
+		{Source: "custom", Name: "ok", Kind: "custom"},
+		{Source: "custom", Name: "broken", Kind: "custom"},
+	}
+	if err := m.UpdateSelected(keys, &mockRunner{}, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := st.GetPackageState("ok"); !ok {
+		t.Fatal("successful update must be recorded")
+	}
+	if _, ok := st.GetPackageState("broken"); ok {
+		t.Fatal("failed update must not be recorded")
+	}
+}
+
+func TestOrphans_AndRemoveOrphan(t *testing.T) {
+	st := newStateForTest(t)
+	if err := st.SetPackageState("kept", state.PackageState{Kind: "custom", Source: "custom"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	gone := state.PackageState{Kind: "source", Source: "apt", Remove: &config.Command{Command: "apt remove -y old"}}
+	if err := st.SetPackageState("old", gone); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	m := New(config.Config{CustomPackages: []config.CustomPackage{{Name: "kept"}}})
+	m.UseState(st)
+
+	orphans := m.Orphans()
+	if len(orphans) != 1 || orphans[0] != (PackageKey{Source: "apt", Name: "old", Kind: "source"}) {
+		t.Fatalf("unexpected orphans: %v", orphans)
+	}
+	run := &mockRunner{}
+	if err := m.RemoveOrphan(orphans[0], run); err != nil {
+		t.Fatalf("remove orphan: %v", err)
+	}
+	if len(run.calls) != 1 || run.calls[0] != "old:remove" {
+		t.Fatalf("unexpected runner calls: %v", run.calls)
+	}
+	if _, ok := st.GetPackageState("old"); ok {
+		t.Fatal("removed orphan must be dropped from state")
+	}
+}
+
+func TestRecordInstalled_StoresRemoveCommand(t *testing.T) {
+	cfg := config.Config{
+		Sources:  []config.Source{{Name: "apt", Install: config.Command{Command: "true"}, Remove: config.Command{Command: "apt remove -y {package_list}", RequireRoot: true}}},
+		Packages: []config.Package{{Name: "git", Source: "apt"}},
+	}
+	m := New(cfg)
+	st := newStateForTest(t)
+	m.UseState(st)
+	if err := m.Install("git"); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	ps, _ := st.GetPackageState("git")
+	if ps.Remove == nil || ps.Remove.Command != "apt remove -y git" || !ps.Remove.RequireRoot {
+		t.Fatalf("expanded remove command not recorded: %#v", ps.Remove)
+	}
+}
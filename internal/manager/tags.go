@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UseTags limits the packages Gopak considers to those carrying at least one
+// of tags and everything they depend on. Packages carrying any of exclude are
+// left out, also when another package depends on them. Without tags every
+// package is a candidate. Unknown tags are an error. Removal never follows
+// dependencies: it only considers the packages that match the tags.
+func (m *Manager) UseTags(tags, exclude []string) error {
+	if len(tags) == 0 && len(exclude) == 0 {
+		m.tagged, m.tagMatched = nil, nil
+		return nil
+	}
+	byTag := m.packagesByTag()
+	for _, t := range append(append([]string{}, tags...), exclude...) {
+		if _, ok := byTag[t]; !ok {
+			return fmt.Errorf("unknown tag %q (available: %s)", t, strings.Join(m.TagNames(), ", "))
+		}
+	}
+	excluded := map[string]bool{}
+	for _, t := range exclude {
+		for _, n := range byTag[t] {
+			excluded[n] = true
+		}
+	}
+	nodes := m.dependencyGraph()
+	var roots []string
+	if len(tags) == 0 {
+		for n := range nodes {
+			roots = append(roots, n)
+		}
+	}
+	for _, t := range tags {
+		roots = append(roots, byTag[t]...)
+	}
+	m.tagged = dependencyClosure(nodes, roots, excluded)
+	m.tagMatched = map[string]bool{}
+	for _, r := range roots {
+		if !excluded[r] {
+			m.tagMatched[r] = true
+		}
+	}
+	return nil
+}
+
+// TagNames returns the tags of the configured packages, sorted.
+func (m *Manager) TagNames() []string {
+	byTag := m.packagesByTag()
+	out := make([]string, 0, len(byTag))
+	for t := range byTag {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// inTags reports whether name passes the tag filters.
+func (m *Manager) inTags(name string) bool {
+	return m.tagged == nil || m.tagged[name]
+}
+
+// checkTags rejects explicit operations on packages outside the tag filters.
+func (m *Manager) checkTags(name string) error {
+	if !m.inTags(name) {
+		return fmt.Errorf("package %s does not match the tag filters", name)
+	}
+	return nil
+}
+
+// checkRemovable rejects removing a package that does not itself match the
+// tag filters, such as a dependency of a tagged package.
+func (m *Manager) checkRemovable(name string) error {
+	if m.tagMatched != nil && !m.tagMatched[name] {
+		return fmt.Errorf("package %s does not match the tag filters", name)
+	}
+	return nil
+}
+
+// Removable returns the packages that the active profiles select and that
+// match the tag filters themselves, grouped like Tracked. Dependencies of
+// tagged packages are not included.
+func (m *Manager) Removable() map[string][]string {
+	out := map[string][]string{}
+	for grp, names := range m.Tracked() {
+		for _, n := range names {
+			if m.checkRemovable(n) == nil {
+				out[grp] = append(out[grp], n)
+			}
+		}
+	}
+	return out
+}
+
+// RemovalOrder orders names so that every package comes before the packages
+// it depends on.
+func (m *Manager) RemovalOrder(names []string) []string {
+	want := map[string]bool{}
+	for _, n := range names {
+		want[n] = true
+	}
+	ord, err := topoOrder(m.dependencyGraph())
+	if err != nil {
+		out := append([]string{}, names...)
+		sort.Strings(out)
+		return out
+	}
+	var out []string
+	for i := len(ord) - 1; i >= 0; i-- {
+		if want[ord[i]] {
+			out = append(out, ord[i])
+		}
+	}
+	return out
+}
+
+// packagesByTag maps every tag to the packages carrying it.
+func (m *Manager) packagesByTag() map[string][]string {
+	out := map[string][]string{}
+	add := func(name string, tags []string) {
+		for _, t := range tags {
+			out[t] = append(out[t], name)
+		}
+	}
+	for _, p := range m.cfg.Packages {
+		add(p.Name, p.Tags)
+	}
+	for _, c := range m.cfg.CustomPackages {
+		add(c.Name, c.Tags)
+	}
+	for _, g := range m.cfg.GithubReleasePackages {
+		add(g.Name, g.Tags)
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+)
+
+func tagConfig() config.Config {
+	cfg := graphConfig()
+	cfg.Packages[0].Tags = []string{"cli"}
+	cfg.CustomPackages[0].Tags = []string{"heavy"}
+	cfg.CustomPackages[1].Tags = []string{"cli", "dev"}
+	cfg.GithubReleasePackages[0].Tags = []string{"cli"}
+	return cfg
+}
+
+func TestUseTags_IncludeAndExclude(t *testing.T) {
+	m := New(tagConfig())
+	if err := m.UseTags([]string{"dev"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"apt":    {"curl", "git"},
+		"custom": {"helper", "tool"},
+	}
+	if got := m.Tracked(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tracked() = %v, want %v", got, want)
+	}
+
+	if err := m.UseTags([]string{"cli"}, []string{"heavy"}); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]string{
+		"apt":    {"curl", "git"},
+		"custom": {"tool"},
+		"github": {"lazygit"},
+	}
+	if got := m.Tracked(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tracked() = %v, want %v", got, want)
+	}
+	if err := m.Install("helper"); err == nil || !strings.Contains(err.Error(), "tag filters") {
+		t.Fatalf("expected tag error, got %v", err)
+	}
+	if err := m.Remove("helper"); err == nil || !strings.Contains(err.Error(), "tag filters") {
+		t.Fatalf("expected tag error, got %v", err)
+	}
+
+	if err := m.UseTags(nil, []string{"cli"}); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]string{"apt": {"curl"}, "custom": {"helper"}}
+	if got := m.Tracked(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tracked() = %v, want %v", got, want)
+	}
+}
+
+func TestUseTags_Unknown(t *testing.T) {
+	m := New(tagConfig())
+	err := m.UseTags([]string{"gui"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "available: cli, dev, heavy") {
+		t.Fatalf("expected unknown tag error, got %v", err)
+	}
+}
+
+func TestUseProfiles_Tags(t *testing.T) {
+	cfg := tagConfig()
+	cfg.Profiles = map[string][]string{"work": {"heavy", "curl"}}
+	m := New(cfg)
+	if err := m.UseProfiles([]string{"work"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"apt": {"curl", "git"}, "custom": {"helper"}}
+	if got := m.Tracked(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tracked() = %v, want %v", got, want)
+	}
+}
+
+func TestRemovalOrder(t *testing.T) {
+	m := New(graphConfig())
+	got := m.RemovalOrder([]string{"git", "tool", "helper"})
+	if want := []string{"tool", "helper", "git"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("RemovalOrder = %v, want %v", got, want)
+	}
+}
@@ -7,6 +7,10 @@ type PackageKey struct {
 }
 
 type VersionStatus struct {
-	Installed string
-	Available string
+	Installed  string
+	Available  string
+	Constraint string
+	Scheme     string
+	// Err describes a probe whose output could not be parsed.
+	Err string
 }
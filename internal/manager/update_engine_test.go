@@ -8,10 +8,14 @@ import (
 	"github.com/the-gopak/gopak-cli/internal/config"
 )
 
-type mockRunner struct{ calls []string }
+type mockRunner struct {
+	calls []string
+	cmds  []string
+}
 
 func (r *mockRunner) Run(name, step string, cmd config.Command) error {
 	r.calls = append(r.calls, name+":"+step)
+	r.cmds = append(r.cmds, cmd.Command)
 	return nil
 }
 func (r *mockRunner) Close() error { return nil }
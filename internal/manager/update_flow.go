@@ -53,126 +53,6 @@ func groupTracked(cfg config.Config) map[string][]string {
 	return res
 }
 
-func (m *Manager) getVersionInstalled(k PackageKey) string {
-	if k.Kind == "custom" {
-		cp := m.customByName(k.Name)
-		if cp.GetInstalledVersion.Command == "" {
-			return ""
-		}
-		res := executil.RunShell(cp.GetInstalledVersion)
-		if res.Code != 0 {
-			return ""
-		}
-		return strings.TrimSpace(res.Stdout)
-	}
-	if k.Kind == "github" {
-		gp := m.githubByName(k.Name)
-		if gp.GetInstalledVersion.Command == "" {
-			return ""
-		}
-		res := executil.RunShell(gp.GetInstalledVersion)
-		if res.Code != 0 {
-			return ""
-		}
-		return strings.TrimSpace(res.Stdout)
-	}
-	src := m.sourceByName(k.Source)
-	if src.Name == "" {
-		return ""
-	}
-	if src.GetInstalledVersion.Command != "" {
-		expanded, err := expandCommandForName(src.GetInstalledVersion, k.Name)
-		if err != nil {
-			return ""
-		}
-		res := executil.RunShell(expanded)
-		if res.Code != 0 {
-			return ""
-		}
-		return strings.TrimSpace(res.Stdout)
-	}
-	return ""
-}
-
-func (m *Manager) getVersionAvailableDryRun(k PackageKey) string {
-	if k.Kind == "custom" {
-		cp := m.customByName(k.Name)
-		if cp.GetLatestVersion.Command == "" {
-			return ""
-		}
-		res := executil.RunShell(cp.GetLatestVersion)
-		if res.Code != 0 {
-			return ""
-		}
-		return strings.TrimSpace(res.Stdout)
-	}
-	if k.Kind == "github" {
-		gp := m.githubByName(k.Name)
-		rel, err := m.ghClient.GetLatestRelease(gp.Repo)
-		if err != nil {
-			return ""
-		}
-		return strings.TrimSpace(rel.TagName)
-	}
-
-	src := m.sourceByName(k.Source)
-	if src.Name == "" {
-		return ""
-	}
-	if src.GetLatestVersion.Command != "" {
-		expanded, err := expandCommandForName(src.GetLatestVersion, k.Name)
-		if err != nil {
-			return ""
-		}
-		res := executil.RunShell(expanded)
-		if res.Code != 0 {
-			return ""
-		}
-		return strings.TrimSpace(res.Stdout)
-	}
-	return ""
-}
-
-func (m *Manager) getVersionAvailable(k PackageKey) string {
-	if k.Kind == "custom" {
-		cp := m.customByName(k.Name)
-		if cp.GetLatestVersion.Command == "" {
-			return ""
-		}
-		res := executil.RunShell(cp.GetLatestVersion)
-		if res.Code != 0 {
-			return ""
-		}
-		return strings.TrimSpace(res.Stdout)
-	}
-	if k.Kind == "github" {
-		gp := m.githubByName(k.Name)
-		rel, err := m.ghClient.GetLatestRelease(gp.Repo)
-		if err != nil {
-			return ""
-		}
-		return strings.TrimSpace(rel.TagName)
-	}
-
-	src := m.sourceByName(k.Source)
-	m.ensurePreUpdate(src)
-	if src.Name == "" {
-		return ""
-	}
-	if src.GetLatestVersion.Command != "" {
-		expanded, err := expandCommandForName(src.GetLatestVersion, k.Name)
-		if err != nil {
-			return ""
-		}
-		res := executil.RunShell(expanded)
-		if res.Code != 0 {
-			return ""
-		}
-		return strings.TrimSpace(res.Stdout)
-	}
-	return ""
-}
-
 func (m *Manager) executeCustomWithRunner(cp config.CustomPackage, op Operation, runner Runner) error {
 	var cmd config.Command
 	switch op {
@@ -188,21 +68,15 @@ func (m *Manager) executeCustomWithRunner(cp config.CustomPackage, op Operation,
 		return nil
 	}
 
-	latest := ""
 	installed := ""
-	if cp.GetLatestVersion.Command != "" {
-		res := executil.RunShell(cp.GetLatestVersion)
-		if res.Code != 0 {
-			return fmt.Errorf("command failed for %s [get_latest_version]: exit %d\n%s", cp.Name, res.Code, res.Stderr)
-		}
-		latest = strings.TrimSpace(res.Stdout)
+	latest, err := m.customLatestVersion(cp)
+	if err != nil {
+		return err
 	}
 	if cp.GetInstalledVersion.Command != "" {
-		res := executil.RunShell(cp.GetInstalledVersion)
-		if res.Code != 0 {
-			return fmt.Errorf("command failed for %s [get_installed_version]: exit %d\n%s", cp.Name, res.Code, res.Stderr)
+		if installed, err = m.readVersion(cp.Name, "get_installed_version", cp.GetInstalledVersion); err != nil {
+			return err
 		}
-		installed = strings.TrimSpace(res.Stdout)
 	}
 
 	need := false
@@ -214,13 +88,16 @@ func (m *Manager) executeCustomWithRunner(cp config.CustomPackage, op Operation,
 			return nil
 		}
 		if latest != "" {
-			need = cmpVersion(latest, installed) > 0
+			need = m.compareVersions(cp.Name, latest, installed) > 0
 		}
 	}
 
 	if !need {
 		return nil
 	}
+	if op == OpInstall && latest == "" && strings.TrimSpace(cp.Version) != "" {
+		return fmt.Errorf("no version of %s satisfies version %q", cp.Name, cp.Version)
+	}
 
 	if op == OpInstall && cp.Remove.Command != "" {
 		if err := runner.Run(cp.Name, "remove-before-install", cp.Remove); err != nil {
@@ -228,20 +105,39 @@ func (m *Manager) executeCustomWithRunner(cp config.CustomPackage, op Operation,
 		}
 	}
 
-	execCmd := config.Command{
-		Command:     fmt.Sprintf("latest_version=%q installed_version=%q; %s", latest, installed, cmd.Command),
-		RequireRoot: cmd.RequireRoot,
+	return runner.Run(cp.Name, string(op), withVersionEnv(cmd, latest, installed))
+}
+
+// withVersionEnv passes the versions of a custom package to its install or
+// update command as the latest_version and installed_version variables.
+func withVersionEnv(cmd config.Command, latest, installed string) config.Command {
+	return withCommandVars(cmd, "latest_version", latest, "installed_version", installed)
+}
+
+// withCommandVars sets the name and value pairs in vars as variables of cmd.
+// They are set in the environment, which every shell reads, and as shell
+// variables for POSIX shells. Variables in cmd.Env take precedence.
+func withCommandVars(cmd config.Command, vars ...string) config.Command {
+	env := map[string]string{}
+	var assigns []string
+	for i := 0; i+1 < len(vars); i += 2 {
+		env[vars[i]] = vars[i+1]
+		assigns = append(assigns, fmt.Sprintf("%s=%q", vars[i], vars[i+1]))
+	}
+	for k, v := range cmd.Env {
+		env[k] = v
 	}
-	return runner.Run(cp.Name, string(op), execCmd)
+	cmd.Env = env
+	if executil.PosixShell(cmd) {
+		cmd.Command = strings.Join(assigns, " ") + "; " + cmd.Command
+	}
+	return cmd
 }
 
 func (m *Manager) executeGithubWithRunner(gp config.GithubReleasePackage, op Operation, runner Runner) error {
-	installed := ""
-	if gp.GetInstalledVersion.Command != "" {
-		res := executil.RunShell(gp.GetInstalledVersion)
-		if res.Code == 0 {
-			installed = strings.TrimSpace(res.Stdout)
-		}
+	installed, err := m.probeVersion(gp.Name, gp.GetInstalledVersion)
+	if err != nil {
+		return err
 	}
 	if op == OpInstall && installed != "" {
 		return nil
@@ -253,42 +149,29 @@ func (m *Manager) executeGithubWithRunner(gp config.GithubReleasePackage, op Ope
 		return nil
 	}
 
-	rel, err := m.ghClient.GetLatestRelease(gp.Repo)
+	rel, err := m.githubRelease(gp)
 	if err != nil {
 		return err
 	}
-	latest := strings.TrimSpace(rel.TagName)
-	if op == OpUpdate && latest != "" && installed != "" && cmpVersion(latest, installed) <= 0 {
-		return nil
-	}
-	asset, err := m.ghClient.FindAsset(rel, gp.AssetPattern)
+	latest, err := m.releaseVersion(gp, rel)
 	if err != nil {
 		return err
 	}
+	if op == OpUpdate && latest != "" && installed != "" && m.compareVersions(gp.Name, latest, installed) <= 0 {
+		return nil
+	}
 	tmpDir, err := os.MkdirTemp("", "gopak-"+gp.Name+"-")
 	if err != nil {
 		return err
 	}
 	defer os.RemoveAll(tmpDir)
-	path, err := m.ghClient.DownloadAsset(asset, tmpDir)
+	path, err := m.downloadGithubAsset(gp, rel, tmpDir)
 	if err != nil {
 		return err
 	}
 	return runner.Run(gp.Name, string(op), githubPostInstallCommand(gp, latest, installed, path))
 }
 
-func (m *Manager) GetVersionInstalled(k PackageKey) string {
-	return m.getVersionInstalled(k)
-}
-
-func (m *Manager) GetVersionAvailable(k PackageKey) string {
-	return m.getVersionAvailable(k)
-}
-
-func (m *Manager) GetVersionAvailableDryRun(k PackageKey) string {
-	return m.getVersionAvailableDryRun(k)
-}
-
 func (m *Manager) HasCommand(k PackageKey, op Operation) bool {
 	if k.Kind == "custom" {
 		cp := m.customByName(k.Name)
@@ -323,11 +206,108 @@ func (m *Manager) HasCommand(k PackageKey, op Operation) bool {
 	return false
 }
 
+// Tracked groups the packages of the active profiles and tag filters by
+// source.
 func (m *Manager) Tracked() map[string][]string {
-	return groupTracked(m.cfg)
+	groups := groupTracked(m.cfg)
+	if m.profile == nil && m.tagged == nil {
+		return groups
+	}
+	out := map[string][]string{}
+	for grp, names := range groups {
+		for _, n := range names {
+			if m.considered(n) {
+				out[grp] = append(out[grp], n)
+			}
+		}
+	}
+	return out
 }
 
+// MsgDependencyFailed is reported for packages that were not attempted because
+// a package they depend on failed.
+const MsgDependencyFailed = "skipped: dependency failed"
+
+// ExecuteSelected runs op for keys in dependency levels. Within a level,
+// source packages are batched per source and everything runs in parallel.
+// Packages whose dependencies failed are reported as skipped.
 func (m *Manager) ExecuteSelected(keys []PackageKey, op Operation, runner Runner, onDone func(PackageKey, bool, string)) error {
+	var install, update []PackageKey
+	if op == OpInstall {
+		install = keys
+	} else {
+		update = keys
+	}
+	return m.ExecutePlan(install, update, runner, func(k PackageKey, _ Operation, ok bool, msg string) {
+		if onDone != nil {
+			onDone(k, ok, msg)
+		}
+	})
+}
+
+// ExecutePlan installs install and updates update in one pass over their
+// combined dependency levels, so an update whose dependency failed to
+// install is skipped like any other dependent.
+func (m *Manager) ExecutePlan(install, update []PackageKey, runner Runner, onDone func(PackageKey, Operation, bool, string)) error {
+	ops := map[PackageKey]Operation{}
+	keys := make([]PackageKey, 0, len(install)+len(update))
+	for _, k := range install {
+		ops[k] = OpInstall
+		keys = append(keys, k)
+	}
+	for _, k := range update {
+		ops[k] = OpUpdate
+		keys = append(keys, k)
+	}
+	levels, deps, err := dependencyLevels(m.dependencyGraph(), keys)
+	if err != nil {
+		for _, k := range keys {
+			if onDone != nil {
+				onDone(k, ops[k], false, err.Error())
+			}
+		}
+		return err
+	}
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	reportFor := func(op Operation) func(PackageKey, bool, string) {
+		return func(k PackageKey, ok bool, msg string) {
+			if ok {
+				m.recordInstalled(k.Name)
+			} else {
+				mu.Lock()
+				failed[k.Name] = true
+				mu.Unlock()
+			}
+			if onDone != nil {
+				onDone(k, op, ok, msg)
+			}
+		}
+	}
+	for _, level := range levels {
+		ready := map[Operation][]PackageKey{}
+		for _, k := range level {
+			blocked := false
+			for _, d := range deps[k.Name] {
+				blocked = blocked || failed[d]
+			}
+			if blocked {
+				reportFor(ops[k])(k, false, MsgDependencyFailed)
+				continue
+			}
+			ready[ops[k]] = append(ready[ops[k]], k)
+		}
+		for _, op := range []Operation{OpInstall, OpUpdate} {
+			if len(ready[op]) > 0 {
+				m.executeLevel(ready[op], op, runner, reportFor(op))
+			}
+		}
+	}
+	return nil
+}
+
+// executeLevel runs op for keys that do not depend on each other.
+func (m *Manager) executeLevel(keys []PackageKey, op Operation, runner Runner, onDone func(PackageKey, bool, string)) {
 	bySrc := map[string][]string{}
 	customSet := map[string]struct{}{}
 	ghSet := map[string]struct{}{}
@@ -363,7 +343,24 @@ func (m *Manager) ExecuteSelected(keys []PackageKey, op Operation, runner Runner
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			group, expanded, err := expandCommandForNames(srcCmd, names)
+			allowed := names[:0]
+			for _, n := range names {
+				if err := m.checkSourceCandidate(n, srcCmd); err != nil {
+					if onDone != nil {
+						onDone(PackageKey{Source: src, Name: n, Kind: KindOf(src)}, false, err.Error())
+					}
+					continue
+				}
+				allowed = append(allowed, n)
+			}
+			if names = allowed; len(names) == 0 {
+				return
+			}
+			srcNames := make([]string, len(names))
+			for i, n := range names {
+				srcNames[i] = m.sourcePackageName(n)
+			}
+			group, expanded, err := expandCommandForNames(srcCmd, srcNames)
 			msgOK := "updated"
 			if op == OpInstall {
 				msgOK = "installed"
@@ -380,7 +377,10 @@ func (m *Manager) ExecuteSelected(keys []PackageKey, op Operation, runner Runner
 
 			if !group {
 				for i, n := range names {
-					err := runner.Run(n, string(op), expanded[i])
+					cmd, err := m.expandSourceVersion(expanded[i], n)
+					if err == nil {
+						err = runner.Run(n, string(op), cmd)
+					}
 					ok := err == nil
 					msg := msgOK
 					if err != nil {
@@ -447,7 +447,6 @@ func (m *Manager) ExecuteSelected(keys []PackageKey, op Operation, runner Runner
 		}()
 	}
 	wg.Wait()
-	return nil
 }
 
 func (m *Manager) UpdateSelected(keys []PackageKey, runner Runner, onUpdate func(PackageKey, bool, string)) error {
@@ -1,7 +1,10 @@
 package manager
 
 import (
+	"errors"
 	"reflect"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/the-gopak/gopak-cli/internal/config"
@@ -38,3 +41,141 @@ func TestKindOf(t *testing.T) {
 		t.Fatalf("KindOf source")
 	}
 }
+
+type orderRunner struct {
+	mu    sync.Mutex
+	calls []string
+	fail  map[string]bool
+}
+
+func (r *orderRunner) Run(name, step string, cmd config.Command) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, name)
+	if r.fail[name] {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func (r *orderRunner) Close() error { return nil }
+
+func TestExecuteSelected_DependencyLevels(t *testing.T) {
+	cfg := config.Config{
+		Sources: []config.Source{{Name: "apt", Install: config.Command{Command: "apt install -y {package_list}"}}},
+		Packages: []config.Package{
+			{Name: "git", Source: "apt"},
+			{Name: "curl", Source: "apt"},
+			{Name: "fish", Source: "apt", DependsOn: []string{"tool"}},
+		},
+		CustomPackages: []config.CustomPackage{{
+			Name:      "tool",
+			DependsOn: []string{"git"},
+			Install:   config.Command{Command: "true"},
+		}},
+	}
+	m := New(cfg)
+	run := &orderRunner{}
+	keys := []PackageKey{
+		{Source: "apt", Name: "fish", Kind: "source"},
+		{Source: "custom", Name: "tool", Kind: "custom"},
+		{Source: "apt", Name: "git", Kind: "source"},
+		{Source: "apt", Name: "curl", Kind: "source"},
+	}
+	if err := m.InstallSelected(keys, run, nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := []string{"apt", "tool", "apt"}
+	if !reflect.DeepEqual(run.calls, want) {
+		t.Fatalf("calls = %v, want %v", run.calls, want)
+	}
+}
+
+func TestExecuteSelected_SkipsDependentsOfFailed(t *testing.T) {
+	cfg := config.Config{
+		CustomPackages: []config.CustomPackage{
+			{Name: "base", Install: config.Command{Command: "true"}},
+			{Name: "mid", DependsOn: []string{"base"}, Install: config.Command{Command: "true"}},
+			{Name: "top", DependsOn: []string{"mid"}, Install: config.Command{Command: "true"}},
+			{Name: "other", Install: config.Command{Command: "true"}},
+		},
+	}
+	m := New(cfg)
+	run := &orderRunner{fail: map[string]bool{"base": true}}
+	var keys []PackageKey
+	for _, n := range []string{"top", "mid", "base", "other"} {
+		keys = append(keys, PackageKey{Source: "custom", Name: n, Kind: "custom"})
+	}
+	msgs := map[string]string{}
+	var mu sync.Mutex
+	if err := m.InstallSelected(keys, run, func(k PackageKey, ok bool, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		msgs[k.Name] = msg
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if msgs["mid"] != "skipped: dependency failed" || msgs["top"] != "skipped: dependency failed" {
+		t.Fatalf("dependents must be skipped: %v", msgs)
+	}
+	if msgs["other"] != "installed" {
+		t.Fatalf("independent package must still run: %v", msgs)
+	}
+	for _, c := range run.calls {
+		if c == "mid" || c == "top" {
+			t.Fatalf("skipped package was attempted: %v", run.calls)
+		}
+	}
+}
+
+func TestExecutePlan_SkipsUpdatesOfFailedInstall(t *testing.T) {
+	cfg := config.Config{
+		CustomPackages: []config.CustomPackage{
+			{Name: "base", Install: config.Command{Command: "true"}},
+			{Name: "app", DependsOn: []string{"base"}, Update: config.Command{Command: "true"}},
+		},
+	}
+	m := New(cfg)
+	run := &orderRunner{fail: map[string]bool{"base": true}}
+	install := []PackageKey{{Source: "custom", Name: "base", Kind: "custom"}}
+	update := []PackageKey{{Source: "custom", Name: "app", Kind: "custom"}}
+	ops := map[string]Operation{}
+	msgs := map[string]string{}
+	var mu sync.Mutex
+	if err := m.ExecutePlan(install, update, run, func(k PackageKey, op Operation, ok bool, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ops[k.Name], msgs[k.Name] = op, msg
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if msgs["app"] != MsgDependencyFailed || ops["app"] != OpUpdate {
+		t.Fatalf("update of a dependent must be skipped: %v %v", ops, msgs)
+	}
+	if !reflect.DeepEqual(run.calls, []string{"base"}) {
+		t.Fatalf("calls = %v, want only base", run.calls)
+	}
+}
+
+func TestCommandVars_Pwsh(t *testing.T) {
+	gp := config.GithubReleasePackage{Name: "tool", PostInstall: config.Command{Command: "Expand-Archive $env:asset_path", Shell: "pwsh"}}
+	cmd := githubPostInstallCommand(gp, "1.2.0", "1.1.0", "/tmp/tool.zip")
+	if cmd.Command != gp.PostInstall.Command {
+		t.Fatalf("pwsh command must not get a POSIX prefix: %q", cmd.Command)
+	}
+	want := map[string]string{"latest_version": "1.2.0", "installed_version": "1.1.0", "asset_path": "/tmp/tool.zip"}
+	if !reflect.DeepEqual(cmd.Env, want) {
+		t.Fatalf("env = %v, want %v", cmd.Env, want)
+	}
+
+	inst := withCommandVars(config.Command{Command: "./install.ps1", Shell: "pwsh", Env: map[string]string{"A": "b"}}, "latest_version", "0.9.4")
+	if inst.Command != "./install.ps1" || inst.Env["latest_version"] != "0.9.4" || inst.Env["A"] != "b" {
+		t.Fatalf("pinned install = %+v", inst)
+	}
+	if runtime.GOOS != "windows" {
+		sh := withCommandVars(config.Command{Command: "make"}, "latest_version", "0.9.4")
+		if sh.Command != `latest_version="0.9.4"; make` {
+			t.Fatalf("POSIX command = %q", sh.Command)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package secret resolves the ${secret:name} references of commands from a
+// local secret file or from secret_command. Resolved values are masked in
+// everything gopak prints or logs.
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/executil"
+	"github.com/the-gopak/gopak-cli/internal/logging"
+)
+
+// DefaultFile is the secret file looked up in the configuration directory
+// when secret_file is not set; DefaultFile+".age" is used when only the
+// encrypted file exists.
+const DefaultFile = "secrets.env"
+
+// Store resolves secrets lazily: the file is read and secret_command runs
+// only when a command references a secret.
+type Store struct {
+	file     string
+	explicit bool
+	identity string
+	command  *config.Command
+	vars     map[string]string
+
+	mu     sync.Mutex
+	loaded bool
+	values map[string]string
+	err    error
+}
+
+// New returns the store configured by cfg. Relative paths are relative to
+// the configuration directory and may use variables such as {home}.
+func New(cfg config.Config) *Store {
+	vars, err := config.ResolveVars(cfg)
+	if err != nil {
+		vars = config.BuiltinVars(cfg.Dir)
+	}
+	path := func(p string) string {
+		p = config.UnescapeRefs(config.ExpandVarRefs(p, vars))
+		if p != "" && !filepath.IsAbs(p) {
+			p = filepath.Join(cfg.Dir, p)
+		}
+		return p
+	}
+	s := &Store{file: path(cfg.SecretFile), explicit: cfg.SecretFile != "", identity: path(cfg.SecretIdentity), command: cfg.SecretCommand, vars: vars}
+	if !s.explicit {
+		s.file = filepath.Join(cfg.Dir, DefaultFile)
+		if _, err := os.Stat(s.file); err != nil {
+			if _, err := os.Stat(s.file + ".age"); err == nil {
+				s.file += ".age"
+			}
+		}
+	}
+	if s.identity == "" {
+		s.identity = filepath.Join(vars["home"], ".config", "age", "keys.txt")
+	}
+	return s
+}
+
+// Lookup returns the value of the secret name from the secret file, or else
+// from the output of secret_command.
+func (s *Store) Lookup(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loaded {
+		s.values, s.err = s.load()
+		s.loaded = true
+		for _, v := range s.values {
+			logging.AddSecret(v)
+		}
+	}
+	if s.err != nil {
+		return "", s.err
+	}
+	if v, ok := s.values[name]; ok {
+		return v, nil
+	}
+	if s.command == nil {
+		return "", fmt.Errorf("secret %q is not defined in %s and no secret_command is configured", name, s.file)
+	}
+	v, err := s.run(name)
+	if err != nil {
+		return "", err
+	}
+	logging.AddSecret(v)
+	s.values[name] = v
+	return v, nil
+}
+
+// run returns the output of secret_command for name, without the trailing
+// newline.
+func (s *Store) run(name string) (string, error) {
+	c := *s.command
+	vars := make(map[string]string, len(s.vars)+1)
+	for k, v := range s.vars {
+		vars[k] = v
+	}
+	vars[config.VarName] = name
+	c.Command = config.ExpandVarRefs(c.Command, vars)
+	if len(config.SecretRefs(c.Command)) > 0 {
+		return "", errors.New("secret_command cannot reference secrets")
+	}
+	res := executil.RunShell(c)
+	if res.Code != 0 {
+		return "", fmt.Errorf("secret_command failed for secret %q: exit %d", name, res.Code)
+	}
+	v := strings.TrimRight(res.Stdout, "\r\n")
+	if v == "" {
+		return "", fmt.Errorf("secret_command printed nothing for secret %q", name)
+	}
+	return v, nil
+}
+
+// load reads the secret file. A missing default file holds no secrets.
+func (s *Store) load() (map[string]string, error) {
+	f, err := os.Open(s.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !s.explicit {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("secret file: %w", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(s.file, ".age") {
+		if r, err = s.decrypt(f); err != nil {
+			return nil, err
+		}
+	} else if err := checkPrivate(f); err != nil {
+		return nil, err
+	}
+	values, err := parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("secret file %s: %w", s.file, err)
+	}
+	return values, nil
+}
+
+// checkPrivate rejects a plaintext secret file that other users can read.
+func checkPrivate(f *os.File) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("secret file %s is accessible by other users; run chmod 600 %s", f.Name(), f.Name())
+	}
+	return nil
+}
+
+// decrypt decrypts an age file with the identities of secret_identity.
+func (s *Store) decrypt(r io.Reader) (io.Reader, error) {
+	b, err := os.ReadFile(s.identity)
+	if err != nil {
+		return nil, fmt.Errorf("secret identity: %w", err)
+	}
+	ids, err := age.ParseIdentities(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("secret identity %s: %w", s.identity, err)
+	}
+	out, err := age.Decrypt(r, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("secret file %s: %w", s.file, err)
+	}
+	return out, nil
+}
+
+// parse reads name=value lines. Blank lines and lines starting with # are
+// skipped; the value is the rest of the line after the first =.
+func parse(r io.Reader) (map[string]string, error) {
+	out := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("line %d: expected name=value", n)
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, sc.Err()
+}
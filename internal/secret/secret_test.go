@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/logging"
+)
+
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookup_PlaintextFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, DefaultFile), "# tokens\nartifacts = tok=en-1\n\nnpm=abc123\n", 0o600)
+	s := New(config.Config{Dir: dir})
+	v, err := s.Lookup("artifacts")
+	if err != nil || v != "tok=en-1" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if got := logging.Redact("Authorization: tok=en-1, npm abc123"); got != "Authorization: ***, npm ***" {
+		t.Fatalf("Redact = %q", got)
+	}
+	if _, err := s.Lookup("missing"); err == nil || !strings.Contains(err.Error(), "no secret_command") {
+		t.Fatalf("missing secret: %v", err)
+	}
+}
+
+func TestLookup_RejectsReadableFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on Windows")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tokens.env"), "a=b\n", 0o644)
+	_, err := New(config.Config{Dir: dir, SecretFile: "tokens.env"}).Lookup("a")
+	if err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLookup_AgeFile(t *testing.T) {
+	dir := t.TempDir()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "key.txt"), id.String()+"\n", 0o600)
+	f, err := os.Create(filepath.Join(dir, DefaultFile+".age"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := age.Encrypt(f, id.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("artifacts=from-age\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	v, err := New(config.Config{Dir: dir, SecretIdentity: "key.txt"}).Lookup("artifacts")
+	if err != nil || v != "from-age" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+}
+
+func TestLookup_SecretCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	dir := t.TempDir()
+	cfg := config.Config{Dir: dir, Vars: map[string]string{"store": "gopak"}, SecretCommand: &config.Command{Command: "echo {store}/{name}-value"}}
+	v, err := New(cfg).Lookup("npm")
+	if err != nil || v != "gopak/npm-value" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+
+	cfg.SecretCommand = &config.Command{Command: "exit 3"}
+	if _, err := New(cfg).Lookup("npm"); err == nil || !strings.Contains(err.Error(), "exit 3") {
+		t.Fatalf("err = %v", err)
+	}
+}
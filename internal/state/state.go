@@ -7,12 +7,19 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
 )
 
 type PackageState struct {
+	Kind          string            `json:"kind,omitempty"`
+	Source        string            `json:"source,omitempty"`
 	Version       string            `json:"version"`
 	InstalledAt   string            `json:"installed_at"`
+	ConfigHash    string            `json:"config_hash,omitempty"`
+	Remove        *config.Command   `json:"remove,omitempty"`
 	FileChecksums map[string]string `json:"file_checksums,omitempty"`
 }
 
@@ -27,7 +34,7 @@ type Manager struct {
 }
 
 func NewManager(configDir string) (*Manager, error) {
-	path := filepath.Join(configDir, "state.json")
+	path := filepath.Join(configDir, config.StateFile)
 	m := &Manager{
 		path:  path,
 		state: State{Packages: make(map[string]PackageState)},
@@ -43,7 +50,13 @@ func (m *Manager) load() error {
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, &m.state)
+	if err := json.Unmarshal(data, &m.state); err != nil {
+		return err
+	}
+	if m.state.Packages == nil {
+		m.state.Packages = make(map[string]PackageState)
+	}
+	return nil
 }
 
 func (m *Manager) save() error {
@@ -117,3 +130,15 @@ func FileChecksum(path string) (string, error) {
 	}
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// Names returns the names of all recorded packages in sorted order.
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.state.Packages))
+	for name := range m.state.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
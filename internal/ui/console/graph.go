@@ -0,0 +1,174 @@
+package console
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/the-gopak/gopak-cli/internal/manager"
+)
+
+// GraphFormats lists the output formats accepted by Graph.
+var GraphFormats = []string{"dot", "mermaid", "json"}
+
+// graphPalette colours graph nodes by source, assigned in source name order.
+var graphPalette = []string{"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462", "#b3de69", "#fccde5", "#d9d9d9", "#bc80bd"}
+
+// unknownColor is used for depends_on entries that are not configured.
+const unknownColor = "#ffffff"
+
+// Graph prints the dependency graph, or the part of it reachable from pkg.
+func (c *ConsoleUI) Graph(pkg, format string) error {
+	nodes, err := c.m.Graph(pkg)
+	if err != nil {
+		return err
+	}
+	out, err := renderGraph(nodes, format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// Why prints every chain of packages that depends on pkg.
+func (c *ConsoleUI) Why(pkg string) error {
+	chains, err := c.m.Why(pkg)
+	if err != nil {
+		return err
+	}
+	fmt.Print(renderWhy(pkg, chains))
+	return nil
+}
+
+func renderGraph(nodes []manager.GraphNode, format string) (string, error) {
+	switch format {
+	case "", "dot":
+		return renderDot(nodes), nil
+	case "mermaid":
+		return renderMermaid(nodes), nil
+	case "json":
+		return renderGraphJSON(nodes)
+	}
+	return "", fmt.Errorf("unknown graph format %q (want %s)", format, strings.Join(GraphFormats, ", "))
+}
+
+func sourceColors(nodes []manager.GraphNode) map[string]string {
+	var srcs []string
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		if n.Source != "" && !seen[n.Source] {
+			seen[n.Source] = true
+			srcs = append(srcs, n.Source)
+		}
+	}
+	sort.Strings(srcs)
+	colors := map[string]string{"": unknownColor}
+	for i, s := range srcs {
+		colors[s] = graphPalette[i%len(graphPalette)]
+	}
+	return colors
+}
+
+// dotEscaper escapes text for a quoted DOT ID or label.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotQuote(s string) string { return `"` + dotEscaper.Replace(s) + `"` }
+
+// mermaidEscaper replaces characters that end or reinterpret a quoted
+// Mermaid label with entity codes.
+var mermaidEscaper = strings.NewReplacer(`#`, "#35;", `"`, "#quot;", `<`, "#lt;", `>`, "#gt;", `[`, "#91;", `]`, "#93;")
+
+func renderDot(nodes []manager.GraphNode) string {
+	colors := sourceColors(nodes)
+	var b strings.Builder
+	b.WriteString("digraph gopak {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=filled];\n")
+	for _, n := range nodes {
+		label := dotEscaper.Replace(n.Name)
+		if n.Source != "" {
+			label += "\\n" + dotEscaper.Replace(n.Source)
+		}
+		fmt.Fprintf(&b, "  %s [label=\"%s\", fillcolor=%q];\n", dotQuote(n.Name), label, colors[n.Source])
+	}
+	for _, n := range nodes {
+		for _, d := range n.DependsOn {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(n.Name), dotQuote(d))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func renderMermaid(nodes []manager.GraphNode) string {
+	colors := sourceColors(nodes)
+	ids := map[string]string{}
+	for i, n := range nodes {
+		ids[n.Name] = fmt.Sprintf("n%d", i)
+	}
+	classes := map[string]string{}
+	var classNames []string
+	for src := range colors {
+		name := "unknown"
+		if src != "" {
+			name = "src_" + mermaidIdent(src)
+		}
+		classes[src] = name
+		classNames = append(classNames, src)
+	}
+	sort.Strings(classNames)
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, n := range nodes {
+		label := mermaidEscaper.Replace(n.Name)
+		if n.Source != "" {
+			label += "<br/>" + mermaidEscaper.Replace(n.Source)
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]:::%s\n", ids[n.Name], label, classes[n.Source])
+	}
+	for _, n := range nodes {
+		for _, d := range n.DependsOn {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[n.Name], ids[d])
+		}
+	}
+	for _, src := range classNames {
+		fmt.Fprintf(&b, "  classDef %s fill:%s,stroke:#333\n", classes[src], colors[src])
+	}
+	return b.String()
+}
+
+func mermaidIdent(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func renderGraphJSON(nodes []manager.GraphNode) (string, error) {
+	b, err := json.MarshalIndent(struct {
+		Nodes []manager.GraphNode `json:"nodes"`
+	}{nodes}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+func renderWhy(pkg string, chains [][]string) string {
+	if len(chains) == 0 {
+		return "nothing depends on " + pkg + "\n"
+	}
+	var b strings.Builder
+	for _, ch := range chains {
+		b.WriteString(strings.Join(ch, " -> ") + "\n")
+	}
+	return b.String()
+}
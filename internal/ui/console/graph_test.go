@@ -0,0 +1,85 @@
+package console
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/manager"
+)
+
+func testGraphNodes() []manager.GraphNode {
+	return []manager.GraphNode{
+		{Name: "git", Source: "apt", Kind: "source", DependsOn: []string{}},
+		{Name: "tool", Source: "custom", Kind: "custom", DependsOn: []string{"git"}},
+	}
+}
+
+func TestRenderGraph(t *testing.T) {
+	dot, err := renderGraph(testGraphNodes(), "dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot, `"tool" -> "git";`) || !strings.Contains(dot, `fillcolor="#8dd3c7"`) {
+		t.Fatalf("dot output: %s", dot)
+	}
+
+	mm, err := renderGraph(testGraphNodes(), "mermaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(mm, "n1 --> n0") || !strings.Contains(mm, "classDef src_apt fill:#8dd3c7") {
+		t.Fatalf("mermaid output: %s", mm)
+	}
+
+	js, err := renderGraph(testGraphNodes(), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Nodes []manager.GraphNode `json:"nodes"`
+	}
+	if err := json.Unmarshal([]byte(js), &parsed); err != nil || len(parsed.Nodes) != 2 {
+		t.Fatalf("json output: %s (%v)", js, err)
+	}
+
+	if _, err := renderGraph(testGraphNodes(), "svg"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestRenderWhy(t *testing.T) {
+	if got := renderWhy("git", [][]string{{"tool", "helper", "git"}}); got != "tool -> helper -> git\n" {
+		t.Fatalf("got %q", got)
+	}
+	if got := renderWhy("git", nil); got != "nothing depends on git\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRenderGraph_EscapesLabels(t *testing.T) {
+	nodes := []manager.GraphNode{
+		{Name: `say"hi]`, Source: `my"src`, Kind: "source", DependsOn: []string{`a\b`}},
+		{Name: `a\b`, Source: "apt", Kind: "source", DependsOn: []string{}},
+	}
+	dot, err := renderGraph(nodes, "dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"say\"hi]" [label="say\"hi]\nmy\"src"`,
+		`"say\"hi]" -> "a\\b";`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("dot output lacks %s:\n%s", want, dot)
+		}
+	}
+
+	mm, err := renderGraph(nodes, "mermaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(mm, `n0["say#quot;hi#93;<br/>my#quot;src"]`) {
+		t.Fatalf("mermaid output: %s", mm)
+	}
+}
@@ -16,7 +16,11 @@ func (c *ConsoleUI) RunListImperative() error {
 	for grp, names := range groups {
 		for _, n := range names {
 			k := manager.PackageKey{Source: grp, Name: n, Kind: kindOf(grp)}
-			installed[k] = c.m.GetVersionInstalled(k)
+			v, err := c.m.ProbeInstalled(k)
+			if err != nil {
+				v = colorRed("error: " + err.Error())
+			}
+			installed[k] = v
 		}
 	}
 	fmt.Print(renderList(groups, installed))
@@ -2,8 +2,10 @@ package console
 
 import (
 	"fmt"
+	"strings"
 
 	survey "github.com/AlecAivazis/survey/v2"
+	"github.com/the-gopak/gopak-cli/internal/manager"
 )
 
 func (c *ConsoleUI) RunRemoveImperative(name string, yes bool) error {
@@ -23,4 +25,46 @@ func (c *ConsoleUI) RunRemoveImperative(name string, yes bool) error {
 	return nil
 }
 
+// RunRemoveTracked removes every installed package that the active profiles
+// select and that matches the tag filters, dependents first. Dependencies of
+// those packages are kept.
+func (c *ConsoleUI) RunRemoveTracked(yes bool) error {
+	var names []string
+	for grp, ns := range c.m.Removable() {
+		for _, n := range ns {
+			k := manager.PackageKey{Source: grp, Name: n, Kind: kindOf(grp)}
+			if v, err := c.m.ProbeInstalled(k); err == nil && v != "" {
+				names = append(names, n)
+			}
+		}
+	}
+	if len(names) == 0 {
+		fmt.Println("Nothing to remove")
+		return nil
+	}
+	names = c.m.RemovalOrder(names)
+	if !yes {
+		ok := false
+		if err := survey.AskOne(&survey.Confirm{Message: messageRemoveConfirm(strings.Join(names, ", ")), Default: false}, &ok); err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+	failed := 0
+	for _, n := range names {
+		if err := c.m.Remove(n); err != nil {
+			fmt.Println(colorRed("failed:  " + n + ": " + err.Error()))
+			failed++
+			continue
+		}
+		fmt.Println("removed:", n)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d package(s) failed to remove", failed)
+	}
+	return nil
+}
+
 func messageRemoveConfirm(name string) string { return fmt.Sprintf("Remove %s?", name) }
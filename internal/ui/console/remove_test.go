@@ -0,0 +1,47 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/manager"
+)
+
+func TestConsoleUIRemoveTracked_KeepsDependencies(t *testing.T) {
+	tmp := t.TempDir()
+	pkg := func(name string, tags []string, deps ...string) config.CustomPackage {
+		return config.CustomPackage{
+			Name:                name,
+			Tags:                tags,
+			DependsOn:           deps,
+			GetInstalledVersion: config.Command{Command: "echo 1.0.0"},
+			Remove:              config.Command{Command: fmt.Sprintf("touch %q", filepath.Join(tmp, name))},
+		}
+	}
+	cfg := config.Config{CustomPackages: []config.CustomPackage{
+		pkg("base", nil),
+		pkg("tool", []string{"cli"}, "base"),
+		pkg("other", nil, "base"),
+	}}
+	m := manager.New(cfg)
+	if err := m.UseTags([]string{"cli"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewConsoleUI(m).RunRemoveTracked(true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "tool")); err != nil {
+		t.Fatal("tool should be removed")
+	}
+	for _, n := range []string{"base", "other"} {
+		if _, err := os.Stat(filepath.Join(tmp, n)); err == nil {
+			t.Fatalf("%s should be kept", n)
+		}
+	}
+	if err := m.Remove("base"); err == nil {
+		t.Fatal("removing a dependency of a tagged package must be rejected")
+	}
+}
@@ -0,0 +1,107 @@
+package console
+
+import (
+	"fmt"
+	"sort"
+
+	survey "github.com/AlecAivazis/survey/v2"
+	"github.com/the-gopak/gopak-cli/internal/manager"
+)
+
+// syncPlan is the combined set of changes that brings the machine in line
+// with the configuration.
+type syncPlan struct {
+	install []manager.PackageKey
+	update  []manager.PackageKey
+	remove  []manager.PackageKey
+}
+
+func (p syncPlan) empty() bool {
+	return len(p.install) == 0 && len(p.update) == 0 && len(p.remove) == 0
+}
+
+// Sync installs missing packages, updates outdated ones and, with prune,
+// removes packages Gopak installed earlier that are no longer configured.
+// The whole plan is shown once and confirmed before anything runs.
+func (c *ConsoleUI) Sync(prune bool, dryRun bool, force bool) error {
+	groups := c.m.Tracked()
+	include := func(k manager.PackageKey) bool {
+		return c.m.HasCommand(k, manager.OpInstall) || c.m.HasCommand(k, manager.OpUpdate)
+	}
+	status, repaint := c.probeVersions(groups, include, dryRun)
+
+	var plan syncPlan
+	for grp, names := range groups {
+		for _, n := range names {
+			k := manager.PackageKey{Source: grp, Name: n, Kind: manager.KindOf(grp)}
+			s := status[k]
+			if filterForInstall(s) && c.m.HasCommand(k, manager.OpInstall) {
+				plan.install = append(plan.install, k)
+			} else if filterForUpdate(s) && c.m.HasCommand(k, manager.OpUpdate) {
+				plan.update = append(plan.update, k)
+			}
+		}
+	}
+	if prune {
+		plan.remove = c.m.Orphans()
+	}
+	sortKeys(plan.install)
+	sortKeys(plan.update)
+	repaint(!plan.empty())
+
+	if plan.empty() {
+		fmt.Println("Nothing to sync")
+		return nil
+	}
+	fmt.Print(renderSyncPlan(plan, status))
+	if dryRun {
+		return nil
+	}
+	if !force {
+		ok := false
+		if err := survey.AskOne(&survey.Confirm{Message: "Apply this plan?", Default: true}, &ok); err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	runner := manager.NewSudoRunner()
+	defer runner.Close()
+	if len(plan.install) > 0 || len(plan.update) > 0 {
+		c.runPlan(plan.install, plan.update, runner)
+	}
+	for _, k := range plan.remove {
+		if err := c.m.RemoveOrphan(k, runner); err != nil {
+			fmt.Println(colorRed("failed:  " + k.Name))
+			fmt.Println(err.Error())
+			continue
+		}
+		fmt.Println(colorGreen("removed: " + k.Name))
+	}
+	return nil
+}
+
+func renderSyncPlan(plan syncPlan, status map[manager.PackageKey]manager.VersionStatus) string {
+	out := "Plan:\n"
+	for _, k := range plan.install {
+		out += "  install: " + labelForInstall(k, status[k]) + "\n"
+	}
+	for _, k := range plan.update {
+		out += "  update:  " + labelForUpdate(k, status[k]) + "\n"
+	}
+	for _, k := range plan.remove {
+		out += fmt.Sprintf("  remove:  %s/%s\n", k.Source, k.Name)
+	}
+	return out
+}
+
+func sortKeys(keys []manager.PackageKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Source != keys[j].Source {
+			return keys[i].Source < keys[j].Source
+		}
+		return keys[i].Name < keys[j].Name
+	})
+}
@@ -0,0 +1,65 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/the-gopak/gopak-cli/internal/config"
+	"github.com/the-gopak/gopak-cli/internal/manager"
+	"github.com/the-gopak/gopak-cli/internal/state"
+)
+
+func syncTestConfig(dir string) config.Config {
+	return config.Config{CustomPackages: []config.CustomPackage{{
+		Name:             "missing",
+		GetLatestVersion: config.Command{Command: "echo 1.0.0"},
+		Install:          config.Command{Command: fmt.Sprintf("touch %q", filepath.Join(dir, "installed"))},
+	}, {
+		Name:                "outdated",
+		GetInstalledVersion: config.Command{Command: "echo 1.0.0"},
+		GetLatestVersion:    config.Command{Command: "echo 2.0.0"},
+		Update:              config.Command{Command: fmt.Sprintf("touch %q", filepath.Join(dir, "updated"))},
+	}}}
+}
+
+func TestConsoleUISync_DryRunDoesNotExecute(t *testing.T) {
+	tmp := t.TempDir()
+	ui := NewConsoleUI(manager.New(syncTestConfig(tmp)))
+	if err := ui.Sync(false, true, true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, f := range []string{"installed", "updated"} {
+		if _, err := os.Stat(filepath.Join(tmp, f)); err == nil {
+			t.Fatalf("%s marker should not be created in dry-run", f)
+		}
+	}
+}
+
+func TestConsoleUISync_InstallsUpdatesAndPrunes(t *testing.T) {
+	tmp := t.TempDir()
+	st, err := state.NewManager(tmp)
+	if err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	removed := filepath.Join(tmp, "removed")
+	orphan := state.PackageState{Kind: "custom", Source: "custom", Remove: &config.Command{Command: fmt.Sprintf("touch %q", removed)}}
+	if err := st.SetPackageState("dropped", orphan); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	m := manager.New(syncTestConfig(tmp))
+	m.UseState(st)
+	ui := NewConsoleUI(m)
+	if err := ui.Sync(true, false, true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, f := range []string{"installed", "updated", "removed"} {
+		if _, err := os.Stat(filepath.Join(tmp, f)); err != nil {
+			t.Fatalf("%s marker should be created by sync", f)
+		}
+	}
+	if _, ok := st.GetPackageState("dropped"); ok {
+		t.Fatal("pruned package must be dropped from state")
+	}
+}
@@ -8,12 +8,14 @@ import (
 
 	survey "github.com/AlecAivazis/survey/v2"
 	"github.com/the-gopak/gopak-cli/internal/manager"
+	"github.com/the-gopak/gopak-cli/internal/version"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
 )
 
 type packageEvent struct {
 	k   manager.PackageKey
+	op  manager.Operation
 	ok  bool
 	msg string
 }
@@ -22,53 +24,46 @@ type filterFunc func(status manager.VersionStatus) bool
 
 type labelFunc func(k manager.PackageKey, status manager.VersionStatus) string
 
-func versionsEqual(installed, available string) bool {
+func versionsEqual(scheme version.Scheme, installed, available string) bool {
 	if installed == "" || available == "" {
 		return installed == available
 	}
-	ni := manager.NormalizeVersion(installed)
-	na := manager.NormalizeVersion(available)
-	if ni == "" || na == "" {
+	if !scheme.Valid(installed) || !scheme.Valid(available) {
 		return installed == available
 	}
-	return manager.CompareVersions(na, ni) == 0
+	return scheme.Compare(available, installed) == 0
 }
 
-func versionsNeedUpdate(installed, available string) bool {
+func versionsNeedUpdate(scheme version.Scheme, installed, available string) bool {
 	if installed == "" || available == "" {
 		return false
 	}
-	ni := manager.NormalizeVersion(installed)
-	na := manager.NormalizeVersion(available)
-	if ni == "" || na == "" {
+	if !scheme.Valid(installed) || !scheme.Valid(available) {
 		return installed != available
 	}
-	return manager.CompareVersions(na, ni) > 0
+	return scheme.Compare(available, installed) > 0
 }
 
-func displayVersion(v string) string {
-	n := manager.NormalizeVersion(v)
-	if n != "" {
-		return n
-	}
-	return v
+func displayVersion(scheme version.Scheme, v string) string {
+	return scheme.Display(v)
 }
 
 func filterForUpdate(s manager.VersionStatus) bool {
-	return versionsNeedUpdate(s.Installed, s.Available)
+	return s.Err == "" && versionsNeedUpdate(version.Scheme(s.Scheme), s.Installed, s.Available) && manager.SatisfiesConstraint(s.Scheme, s.Constraint, s.Available)
 }
 
 func filterForInstall(s manager.VersionStatus) bool {
-	return s.Installed == ""
+	return s.Err == "" && s.Installed == ""
 }
 
 func labelForUpdate(k manager.PackageKey, s manager.VersionStatus) string {
-	return fmt.Sprintf("%s/%s %s -> %s", k.Source, k.Name, displayVersion(s.Installed), displayVersion(s.Available))
+	sc := version.Scheme(s.Scheme)
+	return fmt.Sprintf("%s/%s %s -> %s", k.Source, k.Name, displayVersion(sc, s.Installed), displayVersion(sc, s.Available))
 }
 
 func labelForInstall(k manager.PackageKey, s manager.VersionStatus) string {
 	if s.Available != "" {
-		return fmt.Sprintf("%s/%s %s", k.Source, k.Name, displayVersion(s.Available))
+		return fmt.Sprintf("%s/%s %s", k.Source, k.Name, displayVersion(version.Scheme(s.Scheme), s.Available))
 	}
 	return fmt.Sprintf("%s/%s", k.Source, k.Name)
 }
@@ -88,11 +83,12 @@ func (c *ConsoleUI) Update(name string, dryRun bool, force bool) error {
 			return nil
 		}
 		av := c.m.GetVersionAvailableDryRun(k)
-		if versionsNeedUpdate(ins, av) {
-			fmt.Printf("update: %s/%s %s -> %s\n", k.Source, k.Name, displayVersion(ins), displayVersion(av))
+		sc := version.Scheme(c.m.VersionScheme(k))
+		if versionsNeedUpdate(sc, ins, av) {
+			fmt.Printf("update: %s/%s %s -> %s\n", k.Source, k.Name, displayVersion(sc, ins), displayVersion(sc, av))
 			return nil
 		}
-		fmt.Printf("up-to-date: %s/%s %s\n", k.Source, k.Name, displayVersion(ins))
+		fmt.Printf("up-to-date: %s/%s %s\n", k.Source, k.Name, displayVersion(sc, ins))
 		return nil
 	}
 
@@ -120,12 +116,13 @@ func (c *ConsoleUI) Install(name string, dryRun bool, force bool) error {
 		for _, k := range keys {
 			ins := c.m.GetVersionInstalled(k)
 			av := c.m.GetVersionAvailableDryRun(k)
+			sc := version.Scheme(c.m.VersionScheme(k))
 			if ins != "" {
-				fmt.Printf("skip (already installed): %s/%s %s\n", k.Source, k.Name, displayVersion(ins))
+				fmt.Printf("skip (already installed): %s/%s %s\n", k.Source, k.Name, displayVersion(sc, ins))
 				continue
 			}
 			if av != "" {
-				fmt.Printf("install: %s/%s -> %s\n", k.Source, k.Name, displayVersion(av))
+				fmt.Printf("install: %s/%s -> %s\n", k.Source, k.Name, displayVersion(sc, av))
 			} else {
 				fmt.Printf("install: %s/%s\n", k.Source, k.Name)
 			}
@@ -156,58 +153,7 @@ func (c *ConsoleUI) packageSelectionFlow(
 	force bool,
 ) error {
 	groups := c.m.Tracked()
-	status := map[manager.PackageKey]manager.VersionStatus{}
-	lastLines := 0
-	repaint := func(hideCompleted bool) {
-		out := renderGroups(groups, status, hideCompleted)
-		if lastLines > 0 {
-			fmt.Printf("\x1b[%dA", lastLines)
-			fmt.Print("\x1b[J")
-		}
-		fmt.Print(out)
-		lastLines = strings.Count(out, "\n")
-	}
-	repaint(false)
-
-	updates := make(chan struct{}, 32)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	for grp, names := range groups {
-		for _, n := range names {
-			k := manager.PackageKey{Source: grp, Name: n, Kind: manager.KindOf(grp)}
-			if !c.m.HasCommand(k, op) {
-				continue
-			}
-			wg.Add(1)
-			go func(k manager.PackageKey) {
-				defer wg.Done()
-				ins := c.m.GetVersionInstalled(k)
-				mu.Lock()
-				s := status[k]
-				s.Installed = ins
-				status[k] = s
-				mu.Unlock()
-				updates <- struct{}{}
-
-				av := ""
-				if dryRun {
-					av = c.m.GetVersionAvailableDryRun(k)
-				} else {
-					av = c.m.GetVersionAvailable(k)
-				}
-				mu.Lock()
-				s = status[k]
-				s.Available = av
-				status[k] = s
-				mu.Unlock()
-				updates <- struct{}{}
-			}(k)
-		}
-	}
-	go func() { wg.Wait(); close(updates) }()
-	for range updates {
-		repaint(false)
-	}
+	status, repaint := c.probeVersions(groups, func(k manager.PackageKey) bool { return c.m.HasCommand(k, op) }, dryRun)
 
 	keysAll := make([]manager.PackageKey, 0)
 	for grp, names := range groups {
@@ -237,35 +183,7 @@ func (c *ConsoleUI) packageSelectionFlow(
 		keysSelected := append([]manager.PackageKey{}, need...)
 		runner := manager.NewSudoRunner()
 		defer runner.Close()
-
-		var wgE sync.WaitGroup
-		evCh := make(chan packageEvent, 16)
-		wgE.Add(1)
-		go func() {
-			defer wgE.Done()
-			_ = c.m.ExecuteSelected(keysSelected, op, runner, func(k manager.PackageKey, ok bool, msg string) {
-				evCh <- packageEvent{k: k, ok: ok, msg: msg}
-			})
-		}()
-		go func() { wgE.Wait(); close(evCh) }()
-
-		for e := range evCh {
-			if e.ok {
-				action := e.msg
-				if action == "" {
-					action = "updated"
-					if op == manager.OpInstall {
-						action = "installed"
-					}
-				}
-				fmt.Println(colorGreen(action + ": " + e.k.Name))
-			} else {
-				fmt.Println(colorRed("failed:  " + e.k.Name))
-				if e.msg != "" {
-					fmt.Println(e.msg)
-				}
-			}
-		}
+		c.runSelected(keysSelected, op, runner)
 		return nil
 	}
 
@@ -301,14 +219,91 @@ func (c *ConsoleUI) packageSelectionFlow(
 
 	runner := manager.NewSudoRunner()
 	defer runner.Close()
+	c.runSelected(keysSelected, op, runner)
+	return nil
+}
+
+// probeVersions queries installed and available versions for every tracked
+// package accepted by include, repainting the grouped table as results arrive.
+// The returned repaint redraws the table over its previous output.
+func (c *ConsoleUI) probeVersions(groups map[string][]string, include func(manager.PackageKey) bool, dryRun bool) (map[manager.PackageKey]manager.VersionStatus, func(bool)) {
+	status := map[manager.PackageKey]manager.VersionStatus{}
+	lastLines := 0
+	repaint := func(hideCompleted bool) {
+		out := renderGroups(groups, status, hideCompleted)
+		if lastLines > 0 {
+			fmt.Printf("\x1b[%dA", lastLines)
+			fmt.Print("\x1b[J")
+		}
+		fmt.Print(out)
+		lastLines = strings.Count(out, "\n")
+	}
+	repaint(false)
 
+	updates := make(chan struct{}, 32)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for grp, names := range groups {
+		for _, n := range names {
+			k := manager.PackageKey{Source: grp, Name: n, Kind: manager.KindOf(grp)}
+			if !include(k) {
+				continue
+			}
+			wg.Add(1)
+			go func(k manager.PackageKey) {
+				defer wg.Done()
+				ins, err := c.m.ProbeInstalled(k)
+				mu.Lock()
+				s := status[k]
+				s.Installed = ins
+				if err != nil {
+					s.Err = err.Error()
+				}
+				s.Constraint = c.m.VersionConstraint(k)
+				s.Scheme = c.m.VersionScheme(k)
+				status[k] = s
+				mu.Unlock()
+				updates <- struct{}{}
+
+				av, err := c.m.ProbeAvailable(k, dryRun)
+				mu.Lock()
+				s = status[k]
+				s.Available = av
+				if err != nil && s.Err == "" {
+					s.Err = err.Error()
+				}
+				status[k] = s
+				mu.Unlock()
+				updates <- struct{}{}
+			}(k)
+		}
+	}
+	go func() { wg.Wait(); close(updates) }()
+	for range updates {
+		repaint(false)
+	}
+	return status, repaint
+}
+
+// runSelected executes op for keys and prints one line per finished package.
+func (c *ConsoleUI) runSelected(keys []manager.PackageKey, op manager.Operation, runner manager.Runner) {
+	if op == manager.OpInstall {
+		c.runPlan(keys, nil, runner)
+	} else {
+		c.runPlan(nil, keys, runner)
+	}
+}
+
+// runPlan installs install and updates update in one dependency-ordered pass
+// and prints one line per finished package.
+func (c *ConsoleUI) runPlan(install, update []manager.PackageKey, runner manager.Runner) {
 	var wgE sync.WaitGroup
 	evCh := make(chan packageEvent, 16)
 	wgE.Add(1)
 	go func() {
 		defer wgE.Done()
-		_ = c.m.ExecuteSelected(keysSelected, op, runner, func(k manager.PackageKey, ok bool, msg string) {
-			evCh <- packageEvent{k: k, ok: ok, msg: msg}
+		_ = c.m.ExecutePlan(install, update, runner, func(k manager.PackageKey, op manager.Operation, ok bool, msg string) {
+			evCh <- packageEvent{k: k, op: op, ok: ok, msg: msg}
 		})
 	}()
 	go func() { wgE.Wait(); close(evCh) }()
@@ -318,11 +313,13 @@ func (c *ConsoleUI) packageSelectionFlow(
 			action := e.msg
 			if action == "" {
 				action = "updated"
-				if op == manager.OpInstall {
+				if e.op == manager.OpInstall {
 					action = "installed"
 				}
 			}
 			fmt.Println(colorGreen(action + ": " + e.k.Name))
+		} else if e.msg == manager.MsgDependencyFailed {
+			fmt.Println(colorRed("skipped: " + e.k.Name + " (dependency failed)"))
 		} else {
 			fmt.Println(colorRed("failed:  " + e.k.Name))
 			if e.msg != "" {
@@ -330,11 +327,11 @@ func (c *ConsoleUI) packageSelectionFlow(
 			}
 		}
 	}
-	return nil
 }
 
 func renderGroups(groups map[string][]string, status map[manager.PackageKey]manager.VersionStatus, hideUpToDate bool) string {
 	var b strings.Builder
+	var probeErrs []string
 	keys := make([]string, 0, len(groups))
 	for k := range groups {
 		keys = append(keys, k)
@@ -357,22 +354,26 @@ func renderGroups(groups map[string][]string, status map[manager.PackageKey]mana
 		for _, name := range names {
 			k := manager.PackageKey{Source: grp, Name: name, Kind: manager.KindOf(grp)}
 			s := status[k]
+			sc := version.Scheme(s.Scheme)
 			cur := ""
 			ins := ""
-			if s.Installed != "" || s.Available != "" {
+			if s.Err != "" {
+				cur = colorRed("error")
+				probeErrs = append(probeErrs, s.Err)
+			} else if s.Installed != "" || s.Available != "" {
 				if s.Available == "" {
-					cur = displayVersion(s.Installed)
+					cur = displayVersion(sc, s.Installed)
 				} else if s.Installed == "" {
-					ins = displayVersion(s.Available)
-				} else if versionsEqual(s.Installed, s.Available) {
+					ins = displayVersion(sc, s.Available)
+				} else if versionsEqual(sc, s.Installed, s.Available) {
 					if hideUpToDate {
 						continue
 					}
-					cur = text.FgHiBlack.Sprint(displayVersion(s.Installed))
-					ins = text.FgHiBlack.Sprint(displayVersion(s.Available))
+					cur = text.FgHiBlack.Sprint(displayVersion(sc, s.Installed))
+					ins = text.FgHiBlack.Sprint(displayVersion(sc, s.Available))
 				} else {
-					cur = colorGreen(displayVersion(s.Installed))
-					ins = colorGreen(displayVersion(s.Available))
+					cur = colorGreen(displayVersion(sc, s.Installed))
+					ins = colorGreen(displayVersion(sc, s.Available))
 				}
 			}
 			tw.AppendRow(table.Row{name, cur, ins})
@@ -383,6 +384,9 @@ func renderGroups(groups map[string][]string, status map[manager.PackageKey]mana
 			b.WriteString("\n\n")
 		}
 	}
+	for _, e := range probeErrs {
+		b.WriteString(colorRed("probe error: ") + e + "\n")
+	}
 	return b.String()
 }
 
@@ -9,6 +9,7 @@ import (
 
 	"github.com/the-gopak/gopak-cli/internal/config"
 	"github.com/the-gopak/gopak-cli/internal/manager"
+	"github.com/the-gopak/gopak-cli/internal/version"
 )
 
 func TestRenderGroups_HideUpToDate(t *testing.T) {
@@ -128,3 +129,39 @@ func TestConsoleUIInstall_ForceExecutes(t *testing.T) {
 		t.Fatalf("marker should be created in force mode")
 	}
 }
+
+func TestFilterForUpdate_HonoursConstraint(t *testing.T) {
+	s := manager.VersionStatus{Installed: "1.4.0", Available: "2.0.0"}
+	if !filterForUpdate(s) {
+		t.Fatal("newer version should be offered without a constraint")
+	}
+	s.Constraint = "<2"
+	if filterForUpdate(s) {
+		t.Fatal("update outside the version constraint must not be offered")
+	}
+}
+
+func TestVersionsNeedUpdate_Scheme(t *testing.T) {
+	if versionsNeedUpdate(version.Numeric, "1:2.3-1ubuntu2", "1:2.3-1ubuntu10") {
+		t.Fatal("numeric scheme only sees the epoch")
+	}
+	if !versionsNeedUpdate(version.Debian, "1:2.3-1ubuntu2", "1:2.3-1ubuntu10") {
+		t.Fatal("debian revision bump must be an update")
+	}
+	if versionsNeedUpdate(version.Debian, "2.3-1", "2.3~rc1-1") {
+		t.Fatal("debian ~rc sorts before the release")
+	}
+}
+
+func TestRenderGroups_ProbeError(t *testing.T) {
+	groups := map[string][]string{"custom": {"tool"}}
+	k := manager.PackageKey{Source: "custom", Name: "tool", Kind: "custom"}
+	s := manager.VersionStatus{Err: `version_regex "v(?P<version>.*)" did not match "tool dev" for tool`}
+	out := renderGroups(groups, map[manager.PackageKey]manager.VersionStatus{k: s}, true)
+	if !strings.Contains(out, "error") || !strings.Contains(out, "did not match") {
+		t.Fatalf("probe error not rendered: %q", out)
+	}
+	if filterForInstall(s) || filterForUpdate(s) {
+		t.Fatal("a package that failed to probe must not be selectable")
+	}
+}
@@ -0,0 +1,67 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// calverTokens splits a calendar version such as "2024.01.15", "24.04-1" or
+// "2024.10.0-beta" into runs of digits and runs of letters. Separators only
+// delimit runs.
+func calverTokens(v string) []string {
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V")
+	var out []string
+	for i := 0; i < len(v); {
+		c := v[i]
+		if !isDigit(c) && !isAlpha(c) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(v) && isDigit(v[j]) == isDigit(c) && (isDigit(v[j]) || isAlpha(v[j])) {
+			j++
+		}
+		out = append(out, v[i:j])
+		i = j
+	}
+	return out
+}
+
+func calverValid(v string) bool {
+	t := calverTokens(v)
+	return len(t) > 0 && isDigit(t[0][0])
+}
+
+// compareCalver compares numeric runs by value, so "2024.01" equals
+// "2024.1". A run of letters marks a prerelease and sorts below a number or
+// the end of the version.
+func compareCalver(a, b string) int {
+	ta, tb := calverTokens(a), calverTokens(b)
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		x, y := "0", "0"
+		if i < len(ta) {
+			x = ta[i]
+		}
+		if i < len(tb) {
+			y = tb[i]
+		}
+		dx, dy := isDigit(x[0]), isDigit(y[0])
+		switch {
+		case dx && dy:
+			nx, _ := strconv.Atoi(x)
+			ny, _ := strconv.Atoi(y)
+			if nx != ny {
+				return cmpInt(nx, ny)
+			}
+		case dx:
+			return 1
+		case dy:
+			return -1
+		default:
+			if r := strings.Compare(x, y); r != 0 {
+				return r
+			}
+		}
+	}
+	return 0
+}
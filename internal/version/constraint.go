@@ -0,0 +1,135 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a conjunction of comparisons parsed from the version
+// field of a package, for example "0.9.4", ">=1.2 <2", "~1.4" or "^2".
+// Versions are ordered by the scheme it was parsed for.
+type Constraint struct {
+	scheme  Scheme
+	clauses []clause
+}
+
+type clause struct {
+	op      string
+	version string
+	// release makes an upper bound also exclude prereleases of it, so
+	// "~1.2" does not admit 1.3.0-rc1.
+	release bool
+}
+
+// ParseConstraint parses s for versions of scheme.
+func ParseConstraint(s string, scheme Scheme) (Constraint, error) {
+	c := Constraint{scheme: scheme}
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		op := ""
+		for _, prefix := range []string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"} {
+			if strings.HasPrefix(f, prefix) {
+				op = prefix
+				break
+			}
+		}
+		v := strings.TrimPrefix(f, op)
+		if v == "" && op != "" && i+1 < len(fields) {
+			i++
+			v = fields[i]
+		}
+		if v == "" || !scheme.Valid(v) {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q", s)
+		}
+		switch op {
+		case "", "==":
+			op = "="
+		case "~", "^":
+			if scheme == String || len(Numbers(v)) == 0 {
+				return Constraint{}, fmt.Errorf("invalid version constraint %q: %s needs a numeric version", s, op)
+			}
+			i := tildeIndex(v)
+			if op == "^" {
+				i = caretIndex(v)
+			}
+			c.clauses = append(c.clauses, clause{op: ">=", version: v}, clause{op: "<", version: bumpVersion(v, i), release: true})
+			continue
+		}
+		c.clauses = append(c.clauses, clause{op: op, version: v})
+	}
+	if len(c.clauses) == 0 {
+		return Constraint{}, fmt.Errorf("invalid version constraint %q", s)
+	}
+	return c, nil
+}
+
+// Exact returns the pinned version when the constraint is a single equality.
+func (c Constraint) Exact() (string, bool) {
+	if len(c.clauses) == 1 && c.clauses[0].op == "=" {
+		return c.clauses[0].version, true
+	}
+	return "", false
+}
+
+// Allows reports whether v is valid in the scheme and satisfies every
+// comparison.
+func (c Constraint) Allows(v string) bool {
+	if !c.scheme.Valid(v) {
+		return false
+	}
+	for _, cl := range c.clauses {
+		r := c.scheme.Compare(v, cl.version)
+		ok := false
+		switch cl.op {
+		case "=":
+			ok = r == 0
+		case "!=":
+			ok = r != 0
+		case ">":
+			ok = r > 0
+		case ">=":
+			ok = r >= 0
+		case "<":
+			ok = r < 0 && (!cl.release || compareInts(Numbers(v), Numbers(cl.version)) < 0)
+		case "<=":
+			ok = r <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tildeIndex selects the component bumped by "~": the minor version when one
+// is given ("~1.4" allows 1.4.x), the major version otherwise ("~1" allows 1.x).
+func tildeIndex(v string) int {
+	if len(Numbers(v)) > 1 {
+		return 1
+	}
+	return 0
+}
+
+// caretIndex selects the component bumped by "^": the first non-zero one, so
+// "^1.2" allows 1.x and "^0.3" allows 0.3.x.
+func caretIndex(v string) int {
+	parts := Numbers(v)
+	for i, p := range parts {
+		if p != 0 {
+			return i
+		}
+	}
+	return len(parts) - 1
+}
+
+// bumpVersion increments component i of v and drops everything after it.
+func bumpVersion(v string, i int) string {
+	parts := Numbers(v)
+	parts[i]++
+	out := make([]string, i+1)
+	for j := 0; j <= i; j++ {
+		out[j] = fmt.Sprint(parts[j])
+	}
+	return strings.Join(out, ".")
+}
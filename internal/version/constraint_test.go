@@ -0,0 +1,65 @@
+package version
+
+import "testing"
+
+func TestParseConstraint_Invalid(t *testing.T) {
+	for _, s := range []string{">=", "~", ">=abc"} {
+		if _, err := ParseConstraint(s, Numeric); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestParseConstraint_RangeNeedsNumbers(t *testing.T) {
+	for _, c := range []struct {
+		s      string
+		scheme Scheme
+	}{
+		{"~abc", String},
+		{"^abc", String},
+		{"~1.2", String},
+		{"~v", Numeric},
+	} {
+		if _, err := ParseConstraint(c.s, c.scheme); err == nil {
+			t.Errorf("expected error for %q in scheme %s", c.s, c.scheme)
+		}
+	}
+}
+
+func TestConstraint_UpperBoundExcludesPrereleases(t *testing.T) {
+	for _, c := range []struct {
+		constraint string
+		scheme     Scheme
+		version    string
+		want       bool
+	}{
+		{"~1.2", Semver, "1.2.9", true},
+		{"~1.2", Semver, "1.3.0-rc1", false},
+		{"^1.2", Semver, "2.0.0-beta.1", false},
+		{"~1.2", PEP440, "1.3rc1", false},
+		{"~1.2", Debian, "1.3~rc1", false},
+		{"<1.3", Semver, "1.3.0-rc1", true},
+	} {
+		con, err := ParseConstraint(c.constraint, c.scheme)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.constraint, err)
+		}
+		if got := con.Allows(c.version); got != c.want {
+			t.Errorf("%q allows %q in %s = %v, want %v", c.constraint, c.version, c.scheme, got, c.want)
+		}
+	}
+}
+
+func TestParseConstraint_Exact(t *testing.T) {
+	c, err := ParseConstraint("0.9.4", Numeric)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if v, ok := c.Exact(); !ok || v != "0.9.4" {
+		t.Fatalf("exact = %q, %v", v, ok)
+	}
+	c, _ = ParseConstraint(">=0.9", Numeric)
+	if _, ok := c.Exact(); ok {
+		t.Fatal("range must not be exact")
+	}
+}
@@ -0,0 +1,101 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// debian is a dpkg version: [epoch:]upstream[-revision].
+type debian struct {
+	epoch    int
+	upstream string
+	revision string
+}
+
+func parseDebian(v string) (debian, bool) {
+	var out debian
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		n, err := strconv.Atoi(v[:i])
+		if err != nil || n < 0 {
+			return debian{}, false
+		}
+		out.epoch = n
+		v = v[i+1:]
+	}
+	out.upstream = v
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		out.upstream, out.revision = v[:i], v[i+1:]
+	}
+	if out.upstream == "" || !isDigit(out.upstream[0]) {
+		return debian{}, false
+	}
+	return out, true
+}
+
+func (a debian) compare(b debian) int {
+	if a.epoch != b.epoch {
+		return cmpInt(a.epoch, b.epoch)
+	}
+	if r := verrevcmp(a.upstream, b.upstream); r != 0 {
+		return r
+	}
+	return verrevcmp(a.revision, b.revision)
+}
+
+// debianOrder ranks a non-digit character the way dpkg does: "~" sorts before
+// everything, even the end of the string, and letters sort before symbols.
+func debianOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+// verrevcmp is dpkg's comparison of upstream versions and revisions, which
+// alternates between non-digit runs and numeric runs.
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := debianOrder(a, i), debianOrder(b, j)
+			if ac != bc {
+				return cmpInt(ac, bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		diff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if diff == 0 {
+				diff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if diff != 0 {
+			return cmpInt(diff, 0)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package version
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var pep440Re = regexp.MustCompile(`^v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?:[-_.]?(?P<pre_l>alpha|beta|preview|pre|rc|a|b|c)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?:-(?P<post_n1>[0-9]+)|[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?)?` +
+	`(?:[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`)
+
+// pep440 is a Python package version. Each optional segment is ranked so that
+// a plain comparison of the fields yields the PEP 440 ordering:
+// 1.0.dev1 < 1.0a1 < 1.0 < 1.0+local < 1.0.post1.
+type pep440 struct {
+	epoch   int
+	release []int
+	// preRank is 0-2 for a, b and rc, 3 without a prerelease, and -1 for a
+	// development release of a final version.
+	preRank int
+	preN    int
+	postSet int
+	postN   int
+	// devRank is 0 for a development release and 1 otherwise.
+	devRank int
+	devN    int
+	local   []string
+}
+
+func parsePEP440(v string) (pep440, bool) {
+	m := pep440Re.FindStringSubmatch(strings.ToLower(v))
+	if m == nil {
+		return pep440{}, false
+	}
+	g := func(name string) string { return m[pep440Re.SubexpIndex(name)] }
+	num := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	out := pep440{epoch: num(g("epoch")), preRank: 3, devRank: 1}
+	for _, p := range strings.Split(g("release"), ".") {
+		out.release = append(out.release, num(p))
+	}
+	switch g("pre_l") {
+	case "":
+	case "a", "alpha":
+		out.preRank, out.preN = 0, num(g("pre_n"))
+	case "b", "beta":
+		out.preRank, out.preN = 1, num(g("pre_n"))
+	default:
+		out.preRank, out.preN = 2, num(g("pre_n"))
+	}
+	if n := g("post_n1"); n != "" {
+		out.postSet, out.postN = 1, num(n)
+	} else if g("post_l") != "" {
+		out.postSet, out.postN = 1, num(g("post_n2"))
+	}
+	if g("dev_l") != "" {
+		out.devRank, out.devN = 0, num(g("dev_n"))
+		if out.preRank == 3 && out.postSet == 0 {
+			out.preRank = -1
+		}
+	}
+	if l := g("local"); l != "" {
+		out.local = strings.FieldsFunc(l, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	}
+	return out, true
+}
+
+func (a pep440) compare(b pep440) int {
+	for _, r := range []int{
+		cmpInt(a.epoch, b.epoch),
+		compareInts(a.release, b.release),
+		cmpInt(a.preRank, b.preRank),
+		cmpInt(a.preN, b.preN),
+		cmpInt(a.postSet, b.postSet),
+		cmpInt(a.postN, b.postN),
+		cmpInt(a.devRank, b.devRank),
+		cmpInt(a.devN, b.devN),
+	} {
+		if r != 0 {
+			return r
+		}
+	}
+	return compareLocal(a.local, b.local)
+}
+
+// compareLocal orders local version labels: numeric segments sort above
+// alphanumeric ones, and a longer label wins when one is a prefix of the other.
+func compareLocal(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		r := 0
+		switch {
+		case errA == nil && errB == nil:
+			r = cmpInt(na, nb)
+		case errA == nil:
+			r = 1
+		case errB == nil:
+			r = -1
+		default:
+			r = strings.Compare(a[i], b[i])
+		}
+		if r != 0 {
+			return r
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
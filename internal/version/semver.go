@@ -0,0 +1,77 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semver is a Semantic Versioning 2.0 version. A leading "v" is accepted and
+// missing minor or patch numbers default to 0, as tags often omit them.
+type semver struct {
+	core []int
+	pre  []string
+}
+
+func parseSemver(v string) (semver, bool) {
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "v"), "V")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	core, pre, hasPre := strings.Cut(v, "-")
+	if core == "" || (hasPre && pre == "") {
+		return semver{}, false
+	}
+	var out semver
+	for _, p := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		out.core = append(out.core, n)
+	}
+	if hasPre {
+		out.pre = strings.Split(pre, ".")
+		for _, id := range out.pre {
+			if id == "" {
+				return semver{}, false
+			}
+		}
+	}
+	return out, true
+}
+
+func (a semver) compare(b semver) int {
+	if r := compareInts(a.core, b.core); r != 0 {
+		return r
+	}
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		if r := compareIdentifier(a.pre[i], b.pre[i]); r != 0 {
+			return r
+		}
+	}
+	return cmpInt(len(a.pre), len(b.pre))
+}
+
+// compareIdentifier orders prerelease identifiers: numeric ones by value and
+// below alphanumeric ones, which compare as strings.
+func compareIdentifier(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmpInt(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
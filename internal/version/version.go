@@ -0,0 +1,179 @@
+// Package version compares package versions according to the conventions of
+// the ecosystem they come from.
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Scheme names a version ordering, as set by version_scheme in the config.
+type Scheme string
+
+const (
+	Numeric Scheme = "numeric"
+	Semver  Scheme = "semver"
+	Debian  Scheme = "debian"
+	PEP440  Scheme = "pep440"
+	Calver  Scheme = "calver"
+	String  Scheme = "string"
+)
+
+// Schemes lists every supported scheme.
+var Schemes = []Scheme{Numeric, Semver, Debian, PEP440, Calver, String}
+
+// ParseScheme returns the scheme called name. An empty name selects Numeric.
+func ParseScheme(name string) (Scheme, error) {
+	if name == "" {
+		return Numeric, nil
+	}
+	for _, s := range Schemes {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown version scheme %q", name)
+}
+
+// Compare returns -1, 0 or 1 when a is older than, equal to or newer than b.
+// Versions the scheme cannot parse are compared numerically instead.
+func (s Scheme) Compare(a, b string) int {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	switch s {
+	case Semver:
+		va, okA := parseSemver(a)
+		vb, okB := parseSemver(b)
+		if okA && okB {
+			return va.compare(vb)
+		}
+	case Debian:
+		va, okA := parseDebian(a)
+		vb, okB := parseDebian(b)
+		if okA && okB {
+			return va.compare(vb)
+		}
+	case PEP440:
+		va, okA := parsePEP440(a)
+		vb, okB := parsePEP440(b)
+		if okA && okB {
+			return va.compare(vb)
+		}
+	case Calver:
+		if calverValid(a) && calverValid(b) {
+			return compareCalver(a, b)
+		}
+	case String:
+		return strings.Compare(a, b)
+	}
+	return compareNumeric(a, b)
+}
+
+// Valid reports whether v is a version the scheme can order.
+func (s Scheme) Valid(v string) bool {
+	v = strings.TrimSpace(v)
+	switch s {
+	case Semver:
+		_, ok := parseSemver(v)
+		return ok
+	case Debian:
+		_, ok := parseDebian(v)
+		return ok
+	case PEP440:
+		_, ok := parsePEP440(v)
+		return ok
+	case Calver:
+		return calverValid(v)
+	case String:
+		return v != ""
+	}
+	return Normalize(v) != ""
+}
+
+// Display returns v in the form shown to users. Numeric versions are
+// normalized; other schemes keep every part that affects ordering.
+func (s Scheme) Display(v string) string {
+	if s == "" || s == Numeric {
+		if n := Normalize(v); n != "" {
+			return n
+		}
+	}
+	return strings.TrimSpace(v)
+}
+
+// Normalize extracts the leading dotted number from v, dropping any prefix
+// such as "v" and everything after the first other character.
+func Normalize(s string) string {
+	s = strings.TrimSpace(s)
+	start := 0
+	for start < len(s) && (s[start] < '0' || s[start] > '9') {
+		start++
+	}
+	s = s[start:]
+	end := 0
+	for end < len(s) {
+		c := s[end]
+		if (c < '0' || c > '9') && c != '.' {
+			break
+		}
+		end++
+	}
+	return s[:end]
+}
+
+// Numbers returns the numeric components of the normalized form of v.
+func Numbers(v string) []int {
+	s := Normalize(v)
+	if s == "" {
+		return []int{}
+	}
+	parts := strings.Split(s, ".")
+	out := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			n = 0
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func compareNumeric(a, b string) int {
+	return compareInts(Numbers(a), Numbers(b))
+}
+
+// compareInts compares two component lists, treating missing components as 0.
+func compareInts(a, b []int) int {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		ai, bi := 0, 0
+		if i < len(a) {
+			ai = a[i]
+		}
+		if i < len(b) {
+			bi = b[i]
+		}
+		if ai != bi {
+			return cmpInt(ai, bi)
+		}
+	}
+	return 0
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
@@ -0,0 +1,113 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		scheme Scheme
+		a, b   string
+		want   int
+	}{
+		{Numeric, "1.2.3-beta", "1.2.3", 0},
+		{Numeric, "v2.0.12", "2.0.12", 0},
+		{Numeric, "2", "10", -1},
+
+		{Semver, "1.0.0-rc.1", "1.0.0", -1},
+		{Semver, "1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{Semver, "1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{Semver, "1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{Semver, "1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{Semver, "v1.2", "1.2.0", 0},
+		{Semver, "1.0.0+build.5", "1.0.0", 0},
+
+		{Debian, "1:2.3-1ubuntu2", "2.9-1", 1},
+		{Debian, "2.3~rc1-1", "2.3-1", -1},
+		{Debian, "2.3-1ubuntu2", "2.3-1ubuntu10", -1},
+		{Debian, "2.30", "2.4", 1},
+		{Debian, "1.0", "1.0-0", 0},
+		{Debian, "1.0~~", "1.0~", -1},
+		{Debian, "1.0a", "1.0+", -1},
+
+		{PEP440, "1.0.dev1", "1.0a1", -1},
+		{PEP440, "1.0a1", "1.0b1", -1},
+		{PEP440, "1.0rc1", "1.0", -1},
+		{PEP440, "1.0", "1.0.post1", -1},
+		{PEP440, "1.0.post1.dev1", "1.0.post1", -1},
+		{PEP440, "1.0", "1.0+local", -1},
+		{PEP440, "1.0+abc", "1.0+5", -1},
+		{PEP440, "1.0-1", "1.0.post1", 0},
+		{PEP440, "1.0ALPHA1", "1.0a1", 0},
+		{PEP440, "1!0.1", "2.0", 1},
+		{PEP440, "1.0", "1.0.0", 0},
+
+		{Calver, "2024.01.15", "2024.1.15", 0},
+		{Calver, "2024.10", "2024.9", 1},
+		{Calver, "24.04-1", "24.04", 1},
+		{Calver, "2024.10.0-beta", "2024.10.0", -1},
+		{Calver, "2024-01-15", "2024.01.16", -1},
+
+		{String, "b", "a", 1},
+		{String, "abc", "abc", 0},
+	}
+	for _, c := range cases {
+		if got := c.scheme.Compare(c.a, c.b); got != c.want {
+			t.Errorf("%s.Compare(%q, %q) = %d, want %d", c.scheme, c.a, c.b, got, c.want)
+		}
+		if got := c.scheme.Compare(c.b, c.a); got != -c.want {
+			t.Errorf("%s.Compare(%q, %q) = %d, want %d", c.scheme, c.b, c.a, got, -c.want)
+		}
+	}
+}
+
+func TestCompare_FallsBackToNumeric(t *testing.T) {
+	if got := Semver.Compare("release-2", "release-10"); got != -1 {
+		t.Fatalf("got %d, want -1", got)
+	}
+}
+
+func TestValid(t *testing.T) {
+	cases := []struct {
+		scheme Scheme
+		v      string
+		want   bool
+	}{
+		{Numeric, "v1.2", true},
+		{Numeric, "latest", false},
+		{Semver, "1.0.0-", false},
+		{Semver, "1.0.0-rc.1", true},
+		{Debian, "1:2.3-1", true},
+		{Debian, "x:2.3", false},
+		{PEP440, "1.0.post1", true},
+		{PEP440, "1.0-foo", false},
+		{Calver, "2024.01", true},
+		{Calver, "beta", false},
+		{String, "anything", true},
+		{String, "", false},
+	}
+	for _, c := range cases {
+		if got := c.scheme.Valid(c.v); got != c.want {
+			t.Errorf("%s.Valid(%q) = %v, want %v", c.scheme, c.v, got, c.want)
+		}
+	}
+}
+
+func TestParseScheme(t *testing.T) {
+	if s, err := ParseScheme(""); err != nil || s != Numeric {
+		t.Fatalf("empty scheme: %q, %v", s, err)
+	}
+	if s, err := ParseScheme("debian"); err != nil || s != Debian {
+		t.Fatalf("debian: %q, %v", s, err)
+	}
+	if _, err := ParseScheme("rpm"); err == nil {
+		t.Fatal("expected error for unknown scheme")
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	if got := Numeric.Display("v1.2.3-beta"); got != "1.2.3" {
+		t.Fatalf("numeric display: %q", got)
+	}
+	if got := Debian.Display(" 1:2.3-1ubuntu2 "); got != "1:2.3-1ubuntu2" {
+		t.Fatalf("debian display: %q", got)
+	}
+}